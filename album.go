@@ -0,0 +1,333 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PHASE_SCAN       = "scan"
+	PHASE_METADATA   = "metadata"
+	PHASE_THUMBNAILS = "thumbnails"
+	PHASE_META_FILES = "meta_files"
+)
+
+// AlbumBuilder processes the whole gallery or single albums. Many builders can share one thumbnail pool.
+type AlbumBuilder struct {
+	Options *Options
+	// nil creates no thumbnails, e.g. for a dry run
+	Pool *ThumbnailPool
+	Out  MetaWriter
+}
+
+// Processes the whole gallery: reads all folders and the metadata of new images, creates the missing thumbnails and
+// writes the meta files. The meta files are written, even if some thumbnails failed, the first error is returned.
+func Generate(ctx context.Context, opts *Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	pool := NewThumbnailPool(opts.MaxThreads)
+	defer pool.Close()
+
+	builder := AlbumBuilder{Options: opts, Pool: pool, Out: opts.Output}
+	log.WithField("path", opts.ImagePath).Info("Reading the folders for images...")
+	entry, err := builder.processFolder(ctx, ".")
+	if entry == nil {
+		return err
+	}
+	if indexErr := builder.WriteGeoJsonIndex(); indexErr != nil {
+		return indexErr
+	}
+	return err
+}
+
+// Processes the given album (relative to the root) with all its sub albums and updates its entry in the meta files of
+// all parent folders up to the root. If the album doesn't exist anymore, the entry is removed from its parent.
+func (b *AlbumBuilder) RebuildAlbum(ctx context.Context, albumPath string) error {
+	albumPath, err := CleanAlbumPath(albumPath)
+	if err != nil {
+		return err
+	}
+	folder := albumPath
+	if folder == "" {
+		folder = "."
+	}
+
+	var entry *MetaJsonSubDir
+	var thumbErr error
+	if info, err := fs.Stat(b.Options.Source, folder); err == nil && info.IsDir() {
+		log.WithField("album", folder).Info("Rebuilding album")
+		entry, thumbErr = b.processFolder(ctx, folder)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry == nil {
+			return thumbErr
+		}
+	} else {
+		log.WithField("album", folder).Info("Album was removed")
+	}
+
+	if err := b.UpdateAncestors(ctx, albumPath, entry); err != nil {
+		return err
+	}
+	if err := b.WriteGeoJsonIndex(); err != nil {
+		return err
+	}
+	return thumbErr
+}
+
+// Does the full run for the given folder and returns its entry for the parent folder. The albums are processed one
+// after another, the sub albums first: the metadata is read, the thumbnails are created and the meta files are
+// written. The meta files are written, even if some thumbnails failed, but not if the context is done. The entry is
+// nil, if the meta files of the folder weren't written. With a thumbnail pool, the progress is recorded in the
+// journal, so an interrupted run resumes with the unfinished albums.
+func (b *AlbumBuilder) processFolder(ctx context.Context, folderPath string) (*MetaJsonSubDir, error) {
+	run := &folderRun{durations: make(map[string]time.Duration)}
+	defer b.reportPhases(run)
+
+	endPhase := run.timePhase(PHASE_SCAN)
+	content, err := ReadFolder(b.Options, folderPath)
+	endPhase()
+	if err != nil {
+		CountFailure(PHASE_SCAN)
+		return nil, err
+	}
+
+	if b.Pool != nil {
+		if run.journal, err = openJournal(b.Options.Output); err != nil {
+			return nil, err
+		}
+	}
+	thumbErr, err := b.processAlbum(ctx, run, content)
+	if err != nil {
+		run.journal.flush()
+		return nil, err
+	}
+	run.journal.close(folderPath)
+	log.Debugf("Data model:\n%s\n", content)
+
+	entry := NewSubDirEntry(content)
+	return &entry, thumbErr
+}
+
+// Processes the sub albums and then the album itself. Returns the first thumbnail error and other errors separately.
+func (b *AlbumBuilder) processAlbum(ctx context.Context, run *folderRun, folder *FolderContent) (thumbErr error, err error) {
+	for i := range folder.Folder {
+		subThumbErr, err := b.processAlbum(ctx, run, &folder.Folder[i])
+		if err != nil {
+			return nil, err
+		}
+		if thumbErr == nil {
+			thumbErr = subThumbErr
+		}
+	}
+
+	endPhase := run.timePhase(PHASE_METADATA)
+	err = updateFolderMetaInfos(b.Options, folder, run.journal)
+	endPhase()
+	if err != nil {
+		CountFailure(PHASE_METADATA)
+		return nil, err
+	}
+
+	if b.Pool != nil {
+		endPhase = run.timePhase(PHASE_THUMBNAILS)
+		jobs, err := missingThumbnails(b.Options, folder, run.journal.isPartial)
+		if err != nil {
+			CountFailure(PHASE_THUMBNAILS)
+		} else {
+			err = b.Pool.update(ctx, b.Options, jobs, func(job payload, err error) {
+				if err == nil {
+					run.journal.addThumbnail(job.output)
+				}
+			})
+		}
+		endPhase()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil && thumbErr == nil {
+			thumbErr = err
+		}
+	}
+
+	endPhase = run.timePhase(PHASE_META_FILES)
+	err = writeFolderMetaFiles(folder, b.Options, b.Out)
+	endPhase()
+	if err != nil {
+		CountFailure(PHASE_META_FILES)
+		return nil, err
+	}
+	run.journal.albumDone(folder.FullPath)
+	return thumbErr, nil
+}
+
+// Walks from the album (relative to the root) up to the root and updates the sub folder entry and the time in every
+// meta file on the way. entry is the new entry of the album for its parent, nil if the album was removed.
+// A parent without meta file is processed fully, if the builder has a thumbnail pool.
+func (b *AlbumBuilder) UpdateAncestors(ctx context.Context, albumPath string, entry *MetaJsonSubDir) error {
+	var thumbErr error
+	for dir := path.Clean(albumPath); dir != "." && dir != ""; dir = path.Dir(dir) {
+		parent := path.Dir(dir)
+		found, err := exists(b.Options.Output, path.Join(parent, META_NAME))
+		if err != nil {
+			return err
+		}
+		if !found {
+			if b.Pool == nil {
+				log.WithField("album", parent).Warn("No meta file found, the whole folder has to be processed")
+				return nil
+			}
+			log.WithField("album", parent).Info("No meta file found, processing the whole folder")
+			entry, err = b.processFolder(ctx, parent)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if entry == nil {
+				return err
+			}
+			if err != nil && thumbErr == nil {
+				thumbErr = err
+			}
+			continue
+		}
+
+		if entry, err = b.updateSubDirEntry(parent, path.Base(dir), entry); err != nil {
+			CountFailure(PHASE_META_FILES)
+			return err
+		}
+	}
+	return thumbErr
+}
+
+// Replaces the entry of the sub folder in the meta files of the given folder and recalculates the folder time.
+// Returns the updated entry of the folder for its own parent.
+func (b *AlbumBuilder) updateSubDirEntry(folderPath string, subFolderName string, subEntry *MetaJsonSubDir) (*MetaJsonSubDir, error) {
+	log.WithFields(log.Fields{"album": folderPath, "subAlbum": subFolderName}).Debug("Updating the sub folder entry")
+	meta, err := ReadMetaJson(b.Options.Output, path.Join(folderPath, META_NAME))
+	if err != nil {
+		return nil, err
+	}
+	subDirs := make([]MetaJsonSubDir, 0, len(meta.SubDirs)+1)
+	for _, sub := range meta.SubDirs {
+		if sub.FolderName != subFolderName {
+			subDirs = append(subDirs, sub)
+		}
+	}
+	if subEntry != nil {
+		subDirs = append(subDirs, *subEntry)
+	}
+	sort.Sort(ByTimeDesc{subDirs})
+	meta.SubDirs = subDirs
+
+	var newestTime int64 = math.MinInt64
+	for _, img := range meta.Images {
+		if img.Exif.Time != nil && *img.Exif.Time > newestTime {
+			newestTime = *img.Exif.Time
+		}
+	}
+
+	folderName := b.Options.folderName(folderPath)
+	meta.Meta.Time = ownFolderTime(folderName, newestTime)
+	imageCount := len(meta.Images)
+	for i := range subDirs {
+		// update the folder time if any sub folder has a newer time
+		if meta.Meta.Time == nil || (subDirs[i].Time != nil && *meta.Meta.Time < *subDirs[i].Time) {
+			meta.Meta.Time = subDirs[i].Time
+		}
+		imageCount += subDirs[i].ImageCount
+	}
+
+	if err := writeMetaJsonFiles(b.Out, b.Options, folderPath, meta); err != nil {
+		return nil, err
+	}
+
+	cover, err := albumCover(b.Options.Source, folderPath, meta.Images)
+	if err != nil {
+		return nil, err
+	}
+	return &MetaJsonSubDir{
+		FolderName: folderName,
+		Title:      meta.Meta.Title,
+		Time:       meta.Meta.Time,
+		Cover:      cover,
+		ImageCount: imageCount,
+		Place:      meta.Meta.Place,
+	}, nil
+}
+
+// Returns the cover of the album like in NewSubDirEntry, but without reading the folder.
+func albumCover(source fs.FS, folderPath string, images []MetaJsonImage) (*string, error) {
+	iniFile := path.Join(folderPath, CONTENT_INI)
+	if found, _ := exists(source, iniFile); found {
+		config, err := ReadIniFile(source, iniFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(config.Cover) > 0 {
+			return &config.Cover, nil
+		}
+	}
+
+	// the first image of the folder
+	var cover *string
+	for i := range images {
+		if cover == nil || images[i].Filename < *cover {
+			cover = &images[i].Filename
+		}
+	}
+	return cover, nil
+}
+
+// the state of a single processFolder call
+type folderRun struct {
+	journal *journal
+	// the durations of the phases, summed up over all albums
+	durations map[string]time.Duration
+}
+
+// Starts the time measurement of a phase. Call the returned function at the end of the phase.
+func (r *folderRun) timePhase(phase string) func() {
+	start := time.Now()
+	return func() {
+		r.durations[phase] += time.Since(start)
+	}
+}
+
+// reports the duration of every phase of the run
+func (b *AlbumBuilder) reportPhases(run *folderRun) {
+	for _, phase := range []string{PHASE_SCAN, PHASE_METADATA, PHASE_THUMBNAILS, PHASE_META_FILES} {
+		duration, measured := run.durations[phase]
+		if !measured {
+			continue
+		}
+		PhaseDuration.WithLabelValues(phase).Set(duration.Seconds())
+		log.WithFields(log.Fields{"phase": phase, "duration": duration.Seconds()}).Info("Phase finished")
+		if b.Options.OnPhase != nil {
+			b.Options.OnPhase(phase, duration)
+		}
+	}
+}
+
+// Cleans an album path relative to the image path, "" is the image path itself. The path must be inside of the image
+// path and must not be a .xxxx folder.
+func CleanAlbumPath(albumPath string) (string, error) {
+	albumPath = path.Clean(filepath.ToSlash(albumPath))
+	if albumPath == "." {
+		return "", nil
+	}
+	if path.IsAbs(albumPath) || strings.HasPrefix(albumPath, ".") || strings.Contains(albumPath, "/.") {
+		return "", errors.New("invalid album path: " + albumPath)
+	}
+	return albumPath, nil
+}
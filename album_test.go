@@ -0,0 +1,246 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
+	"path"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memOutput is an in-memory OutputFS. The written files get the time of the clock.
+type memOutput struct {
+	fstest.MapFS
+	clock *testClock
+}
+
+func newMemOutput() memOutput {
+	return memOutput{fstest.MapFS{}, &testClock{time.Unix(1600000000, 0)}}
+}
+
+// testClock is a fake time, which advances a second on every tick, so the order of the modification times is defined
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) tick() time.Time {
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+type memFile struct {
+	bytes.Buffer
+	name string
+	out  memOutput
+}
+
+func (f *memFile) Close() error {
+	return f.out.WriteFile(f.name, f.Bytes())
+}
+
+func (m memOutput) WriteFile(name string, data []byte) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data, ModTime: m.clock.tick()}
+	return nil
+}
+
+func (m memOutput) Create(name string) (io.WriteCloser, error) {
+	return &memFile{name: name, out: m}, nil
+}
+
+func (m memOutput) Remove(name string) error {
+	delete(m.MapFS, name)
+	return nil
+}
+
+// testGallery generates the meta files of the source into an in-memory output with the smallest options.
+type testGallery struct {
+	t      *testing.T
+	source fstest.MapFS
+	out    memOutput
+	opts   Options
+}
+
+func newTestGallery(t *testing.T, source fstest.MapFS) *testGallery {
+	out := newMemOutput()
+	return &testGallery{t: t, source: source, out: out, opts: Options{Source: source, Output: out, Sizes: IntList{8}}}
+}
+
+func (g *testGallery) generate() error {
+	return Generate(context.Background(), &g.opts)
+}
+
+// returns the images of the meta file of the album, "." is the root
+func (g *testGallery) images(album string) []MetaJsonImage {
+	return readTestMeta(g.t, g.out, path.Join(album, META_NAME)).Images
+}
+
+// Changes a source file after the previous run. With keepTime, it has the modification time of before, like a
+// restored backup.
+func (g *testGallery) update(name string, data []byte, keepTime bool) {
+	modTime := g.out.clock.tick()
+	if previous, found := g.source[name]; found && keepTime {
+		modTime = previous.ModTime
+	}
+	g.source[name] = &fstest.MapFile{Data: data, ModTime: modTime}
+}
+
+func testImage(t *testing.T, width int, height int) *fstest.MapFile {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil))
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+func readTestMeta(t *testing.T, out memOutput, name string) MetaJson {
+	var meta MetaJson
+	require.Contains(t, out.MapFS, name)
+	require.NoError(t, json.Unmarshal(out.MapFS[name].Data, &meta))
+	return meta
+}
+
+func Test_Generate_inMemory(t *testing.T) {
+	source := fstest.MapFS{
+		"2015-08-27_Party/a.jpg":       testImage(t, 40, 20),
+		"2015-08-27_Party/b.JPG":       testImage(t, 20, 40),
+		"2015-08-27_Party/content.ini": &fstest.MapFile{Data: []byte("title=Party\ncover=b.JPG")},
+		"2015-08-27_Party/notes.txt":   &fstest.MapFile{Data: []byte("no image")},
+		"Other/c.jpg":                  testImage(t, 10, 10),
+	}
+	gallery := newTestGallery(t, source)
+	out, opts := gallery.out, &gallery.opts
+	require.NoError(t, gallery.generate())
+
+	root := readTestMeta(t, out, META_NAME)
+	require.Len(t, root.SubDirs, 2)
+	require.Equal(t, "Party", root.SubDirs[0].Title)
+	require.Equal(t, "b.JPG", *root.SubDirs[0].Cover)
+	require.Equal(t, 2, root.SubDirs[0].ImageCount)
+
+	party := readTestMeta(t, out, "2015-08-27_Party/"+META_NAME)
+	require.Len(t, party.Images, 2)
+	require.Equal(t, 40, party.Images[0].Width)
+	require.Contains(t, out.MapFS, "2015-08-27_Party/"+THUMB_DIR+"/8-a.jpg")
+	require.Contains(t, out.MapFS, "Other/"+THUMB_DIR+"/8-c.jpg")
+
+	// a new image in one album
+	source["Other/d.jpg"] = testImage(t, 10, 10)
+	pool := NewThumbnailPool(1)
+	defer pool.Close()
+	builder := AlbumBuilder{Options: opts, Pool: pool, Out: out}
+	require.NoError(t, builder.RebuildAlbum(context.Background(), "Other"))
+
+	root = readTestMeta(t, out, META_NAME)
+	require.Equal(t, 2, root.SubDirs[1].ImageCount)
+	require.Contains(t, out.MapFS, "Other/"+THUMB_DIR+"/8-d.jpg")
+}
+
+func Test_Generate_resume(t *testing.T) {
+	source := fstest.MapFS{
+		"A/a.jpg": testImage(t, 10, 10),
+		"B/b.jpg": testImage(t, 10, 10),
+	}
+	out := newMemOutput()
+	ctx, cancel := context.WithCancel(context.Background())
+	thumbnails := 0
+	opts := Options{Source: source, Output: out, Sizes: IntList{8}, MaxThreads: 1,
+		OnThumbnail: func(done int, total int, err error) {
+			// stops the run after the thumbnail of the second album
+			if thumbnails++; thumbnails == 2 {
+				cancel()
+			}
+		}}
+	require.Equal(t, context.Canceled, Generate(ctx, &opts))
+
+	// the first album is finished, the second one is in the journal
+	require.Contains(t, out.MapFS, "A/"+META_NAME)
+	require.NotContains(t, out.MapFS, "B/"+META_NAME)
+	var content journalData
+	require.NoError(t, json.Unmarshal(out.MapFS[JOURNAL_NAME].Data, &content))
+	require.Equal(t, []string{"A"}, content.Albums)
+	require.Contains(t, content.Images, "B/b.jpg")
+	require.Equal(t, []string{"B/" + THUMB_DIR + "/8-b.jpg"}, content.Thumbnails)
+
+	// the metadata of the journal is used and an unrecorded thumbnail of the interrupted run is created again
+	img := content.Images["B/b.jpg"]
+	img.Width = 123
+	content.Images["B/b.jpg"] = img
+	content.Thumbnails = []string{}
+	data, err := json.Marshal(content)
+	require.NoError(t, err)
+	out.MapFS[JOURNAL_NAME] = &fstest.MapFile{Data: data}
+	out.MapFS["B/"+THUMB_DIR+"/8-b.jpg"] = &fstest.MapFile{Data: []byte("partial"), ModTime: time.Now()}
+
+	opts.OnThumbnail = nil
+	require.NoError(t, Generate(context.Background(), &opts))
+	require.Equal(t, 123, readTestMeta(t, out, "B/"+META_NAME).Images[0].Width)
+	require.NotEqual(t, "partial", string(out.MapFS["B/"+THUMB_DIR+"/8-b.jpg"].Data))
+	require.NotContains(t, out.MapFS, JOURNAL_NAME)
+}
+
+func Test_Generate_replacedImage(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		"A/a.jpg": testImage(t, 40, 20),
+		"A/b.jpg": testImage(t, 10, 10),
+	})
+	require.NoError(t, gallery.generate())
+	thumbnail := gallery.out.MapFS["A/"+THUMB_DIR+"/8-a.jpg"]
+	other := gallery.out.MapFS["A/"+THUMB_DIR+"/8-b.jpg"]
+
+	// the metadata and the thumbnail of the replaced image are created again, the other image is kept
+	gallery.update("A/a.jpg", testImage(t, 20, 40).Data, false)
+	require.NoError(t, gallery.generate())
+	images := gallery.images("A")
+	require.Equal(t, 20, images[0].Width)
+	require.NotEqual(t, thumbnail.Data, gallery.out.MapFS["A/"+THUMB_DIR+"/8-a.jpg"].Data)
+	require.True(t, gallery.out.MapFS["A/"+THUMB_DIR+"/8-a.jpg"].ModTime.After(thumbnail.ModTime))
+	require.Equal(t, other.ModTime, gallery.out.MapFS["A/"+THUMB_DIR+"/8-b.jpg"].ModTime)
+}
+
+func Test_AlbumBuilder_RebuildAlbum(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		"A/a.jpg":     testImage(t, 10, 10),
+		"A/B/b.jpg":   testImage(t, 10, 10),
+		"A/C/c.jpg":   testImage(t, 10, 10),
+		"Other/o.jpg": testImage(t, 10, 10),
+	})
+	require.NoError(t, gallery.generate())
+	other := gallery.out.MapFS["Other/"+META_NAME]
+	pool := NewThumbnailPool(1)
+	defer pool.Close()
+	builder := AlbumBuilder{Options: &gallery.opts, Pool: pool, Out: gallery.out}
+	subDir := func(album string, name string) *MetaJsonSubDir {
+		for _, sub := range readTestMeta(t, gallery.out, path.Join(album, META_NAME)).SubDirs {
+			if sub.FolderName == name {
+				return &sub
+			}
+		}
+		return nil
+	}
+
+	// the entries of the ancestors are updated up to the root, the other albums are kept
+	gallery.source["A/B/b2.jpg"] = testImage(t, 10, 10)
+	require.NoError(t, builder.RebuildAlbum(context.Background(), "A/B/"))
+	require.Len(t, gallery.images("A/B"), 2)
+	require.Equal(t, 2, subDir("A", "B").ImageCount)
+	require.Equal(t, 4, subDir(".", "A").ImageCount)
+	require.Equal(t, other.ModTime, gallery.out.MapFS["Other/"+META_NAME].ModTime)
+
+	// a removed album is removed from its parent
+	delete(gallery.source, "A/C/c.jpg")
+	require.NoError(t, builder.RebuildAlbum(context.Background(), "A/C"))
+	require.Nil(t, subDir("A", "C"))
+	require.Equal(t, 3, subDir(".", "A").ImageCount)
+
+	// a parent without meta file is processed fully
+	delete(gallery.out.MapFS, "A/"+META_NAME)
+	require.NoError(t, builder.RebuildAlbum(context.Background(), "A/B"))
+	require.Len(t, gallery.images("A"), 1)
+
+	require.Error(t, builder.RebuildAlbum(context.Background(), "../A"))
+}
@@ -1,2 +1,2 @@
 #!/usr/bin/env bash
-go build -a -ldflags '-w -extldflags "-static"' cli/makeMeta.go
+go build -a -ldflags '-w -extldflags "-static"' -o makeMeta ./cli
@@ -0,0 +1,181 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// the descriptive fields of an image from the xmp or iptc data, all are optional
+type captionData struct {
+	Title       *string
+	Description *string
+	Keywords    []string
+	// 1 to 5 stars, 0 is unrated and -1 is rejected
+	Rating *int
+	// the color label, e.g. "Red"
+	Label *string
+	// the photographer
+	Byline *string
+	// the provider, e.g. the press agency
+	Credit  *string
+	City    *string
+	Country *string
+}
+
+// Reads the descriptive fields of the image (path in the source). The sources in the order of precedence: the xmp
+// sidecar, the xmp embedded in the image and the iptc data of the image. Invalid data is skipped.
+func readCaptions(source fs.FS, input string) (captionData, error) {
+	var result captionData
+	for _, sidecar := range xmpSidecars(input) {
+		data, err := fs.ReadFile(source, sidecar)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result, _ = parseXMP(data)
+		break
+	}
+
+	f, err := source.Open(input)
+	if err != nil {
+		return result, err
+	}
+	defer f.Close()
+	xmp, iptc := findJpegSegments(f)
+	if xmp != nil {
+		if embedded, err := parseXMP(xmp); err == nil {
+			result.merge(embedded)
+		}
+	}
+	if iptc != nil {
+		result.merge(parseIPTC(iptc))
+	}
+	return result, nil
+}
+
+// sets the fields, which are missing, from the other data
+func (c *captionData) merge(other captionData) {
+	if c.Title == nil {
+		c.Title = other.Title
+	}
+	if c.Description == nil {
+		c.Description = other.Description
+	}
+	if c.Keywords == nil {
+		c.Keywords = other.Keywords
+	}
+	if c.Rating == nil {
+		c.Rating = other.Rating
+	}
+	if c.Label == nil {
+		c.Label = other.Label
+	}
+	if c.Byline == nil {
+		c.Byline = other.Byline
+	}
+	if c.Credit == nil {
+		c.Credit = other.Credit
+	}
+	if c.City == nil {
+		c.City = other.City
+	}
+	if c.Country == nil {
+		c.Country = other.Country
+	}
+}
+
+// sets the fields of the image
+func (c *captionData) apply(image *MetaJsonImage) {
+	image.Title, image.Description, image.Keywords = c.Title, c.Description, c.Keywords
+	image.Rating, image.Label = c.Rating, c.Label
+	image.Byline, image.Credit, image.City, image.Country = c.Byline, c.Credit, c.City, c.Country
+}
+
+// Returns the xmp packet of the APP1 segments and the photoshop resources of the APP13 segment of a jpeg, nil if
+// there is none.
+func findJpegSegments(r io.Reader) (xmp []byte, photoshop []byte) {
+	reader := bufio.NewReader(r)
+	var marker [2]byte
+	if _, err := io.ReadFull(reader, marker[:]); err != nil || marker != [2]byte{0xFF, 0xD8} {
+		return nil, nil
+	}
+	for {
+		if _, err := io.ReadFull(reader, marker[:]); err != nil || marker[0] != 0xFF {
+			return xmp, photoshop
+		}
+		// the start of the image data: no more metadata
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return xmp, photoshop
+		}
+		var length uint16
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil || length < 2 {
+			return xmp, photoshop
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(reader, segment); err != nil {
+			return xmp, photoshop
+		}
+		if marker[1] == 0xE1 && xmp == nil && bytes.HasPrefix(segment, []byte(XMP_HEADER)) {
+			xmp = segment[len(XMP_HEADER):]
+		}
+		if marker[1] == 0xED && photoshop == nil && bytes.HasPrefix(segment, []byte(PHOTOSHOP_HEADER)) {
+			photoshop = segment[len(PHOTOSHOP_HEADER):]
+		}
+	}
+}
+
+// the key of the alt text of an image in the captions, e.g. "a.jpg.alt"
+const ALT_TEXT_SUFFIX = ".alt"
+
+// Adds the caption or, with ALT_TEXT_SUFFIX, the alt text of an image.
+func (c *FolderConfig) addCaption(key string, value string) {
+	if c.Captions == nil {
+		c.Captions = make(map[string]string)
+		c.AltTexts = make(map[string]string)
+	}
+	if strings.HasSuffix(key, ALT_TEXT_SUFFIX) {
+		c.AltTexts[strings.TrimSuffix(key, ALT_TEXT_SUFFIX)] = value
+	} else {
+		c.Captions[key] = value
+	}
+}
+
+// overrides the description and the alt text of the image with the ones of the folder config
+func (c *FolderConfig) applyCaptions(image *MetaJsonImage) {
+	if caption, found := c.Captions[image.Filename]; found {
+		image.Description = &caption
+	}
+	if alt, found := c.AltTexts[image.Filename]; found {
+		image.Alt = &alt
+	}
+}
+
+// Reads a captions.txt with "filename = caption" and "filename.alt = alt text" lines. Empty lines and lines starting
+// with # are skipped.
+func readCaptionsFile(fsys fs.FS, captionsFile string) (FolderConfig, error) {
+	var config FolderConfig
+	data, err := fs.ReadFile(fsys, captionsFile)
+	if err != nil {
+		return config, err
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return config, fmt.Errorf("invalid line %d in %s, expected: filename = caption", i+1, captionsFile)
+		}
+		config.addCaption(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return config, nil
+}
@@ -0,0 +1,45 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Generate_captions(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		"A/a.jpg": withEmbeddedXMP(testImage(t, 10, 10), testXMP(`>
+      <dc:description><rdf:Alt><rdf:li xml:lang="x-default">Embedded</rdf:li></rdf:Alt></dc:description>
+    </rdf:Description>`)),
+		"A/b.jpg":        testImage(t, 10, 10),
+		"A/captions.txt": &fstest.MapFile{Data: []byte("# captions\na.jpg = From the file\nb.jpg = B = 2\nb.jpg.alt = A white square\n")},
+		"A/content.ini":  &fstest.MapFile{Data: []byte("title=A\n[images]\na.jpg=From the ini\n")},
+	})
+	require.NoError(t, gallery.generate())
+
+	images := gallery.images("A")
+	require.Equal(t, "From the ini", *images[0].Description)
+	require.Nil(t, images[0].Alt)
+	require.Equal(t, "B = 2", *images[1].Description)
+	require.Equal(t, "A white square", *images[1].Alt)
+	// the config files are recorded in the internal state, not in the meta file
+	require.NotContains(t, string(gallery.out.MapFS["A/"+META_NAME].Data), "captions.txt")
+	require.Contains(t, string(gallery.out.MapFS["A/"+STATE_NAME].Data), "captions.txt")
+
+	// the images are read again without the removed captions.txt
+	delete(gallery.source, "A/captions.txt")
+	require.NoError(t, gallery.generate())
+	images = gallery.images("A")
+	require.Equal(t, "From the ini", *images[0].Description)
+	require.Nil(t, images[1].Description)
+	require.Nil(t, images[1].Alt)
+
+	// and without the captions of the content.ini
+	gallery.update("A/content.ini", []byte("title=A\n"), false)
+	require.NoError(t, gallery.generate())
+	require.Equal(t, "Embedded", *gallery.images("A")[0].Description)
+
+	gallery.update("A/captions.txt", []byte("no caption"), false)
+	require.Error(t, gallery.generate())
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+// adminServer is the http api to start, monitor and cancel rebuild jobs.
+//
+//	POST   /jobs          {"path": "album/sub"}   starts a rebuild of the subtree, "" is the whole tree, see jobRequest
+//	GET    /jobs                                  lists all queued, running and the last finished jobs
+//	GET    /jobs/<id>[?wait=true]                 the job, optionally waits until the job is finished
+//	DELETE /jobs/<id>                             cancels the job
+type adminServer struct {
+	queue *jobQueue
+}
+
+type jobRequest struct {
+	Path string `json:"path"`
+	// optional, the jpeg quality of every thumbnail size of the caller (see thumbnailOptions): a job with other
+	// thumbnails than the daemon's is refused
+	Thumbnails map[int]int `json:"thumbnails,omitempty"`
+}
+
+// Starts the admin api in the background. listen is either a tcp address or "unix:<socket file>".
+func startAdminServer(listen string, queue *jobQueue) {
+	listener, err := adminListener(listen)
+	mfg.CheckError(err, "Can't listen on", listen)
+
+	log.WithField("listen", listen).Info("Admin api started")
+	go func() {
+		err := http.Serve(listener, &adminServer{queue})
+		mfg.CheckError(err, "Admin api stopped.")
+	}()
+}
+
+func adminListener(listen string) (net.Listener, error) {
+	if strings.HasPrefix(listen, "unix:") {
+		socket := strings.TrimPrefix(listen, "unix:")
+		// a left over from a previous run
+		os.Remove(socket)
+		return net.Listen("unix", socket)
+	}
+	return net.Listen("tcp", listen)
+}
+
+func (s *adminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Trim(r.URL.Path, "/")
+	if urlPath == "jobs" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJson(w, http.StatusOK, s.queue.list())
+		case http.MethodPost:
+			s.addJob(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if !strings.HasPrefix(urlPath, "jobs/") {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(urlPath, "jobs/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("wait") == "true" {
+			s.queue.wait(r.Context(), id)
+		}
+		s.writeJob(w, r, id)
+	case http.MethodDelete:
+		if !s.queue.cancel(id) {
+			http.NotFound(w, r)
+			return
+		}
+		s.writeJob(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *adminServer) addJob(w http.ResponseWriter, r *http.Request) {
+	var request jobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Thumbnails != nil && !reflect.DeepEqual(request.Thumbnails, thumbnailOptions(&s.queue.opts.Options)) {
+		http.Error(w, "The daemon creates other thumbnails", http.StatusConflict)
+		return
+	}
+	j, err := s.queue.add(request.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.writeJob(w, r, j.Id)
+}
+
+func (s *adminServer) writeJob(w http.ResponseWriter, r *http.Request, id int) {
+	j, found := s.queue.get(id)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusAccepted
+	}
+	writeJson(w, status, j)
+}
+
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
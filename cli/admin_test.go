@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+// Starts the admin api of a gallery with the album A.
+func newTestAdminServer(t *testing.T, onPhase mfg.PhaseProgress) *httptest.Server {
+	gallery := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(gallery, "A"), 0755))
+	f, err := os.Create(filepath.Join(gallery, "A", "a.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil))
+	require.NoError(t, f.Close())
+
+	opts := &options{}
+	opts.ImagePath = gallery
+	opts.Sizes = mfg.IntList{8}
+	opts.OnPhase = onPhase
+	require.NoError(t, opts.Validate())
+	server := httptest.NewServer(&adminServer{newJobQueue(opts)})
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Sends the request to the admin api and returns the status and the job of the response.
+func adminRequest(t *testing.T, method string, url string, body string) (int, job) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var j job
+	if resp.StatusCode/100 == 2 {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&j))
+	}
+	return resp.StatusCode, j
+}
+
+func Test_adminServer_jobs(t *testing.T) {
+	server := newTestAdminServer(t, nil)
+
+	status, j := adminRequest(t, http.MethodPost, server.URL+"/jobs", `{"path": "A"}`)
+	require.Equal(t, http.StatusAccepted, status)
+	require.Equal(t, "A", j.Path)
+
+	status, j = adminRequest(t, http.MethodGet, server.URL+"/jobs/1?wait=true", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, JOB_DONE, j.State)
+	require.Equal(t, 1, j.ThumbnailsDone)
+
+	for _, invalid := range []struct {
+		method string
+		url    string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/jobs", `{"path": "../B"}`, http.StatusBadRequest},
+		{http.MethodPost, "/jobs", `{"path": "A", "thumbnails": {"8": 50}}`, http.StatusConflict},
+		{http.MethodGet, "/jobs/2", "", http.StatusNotFound},
+		{http.MethodDelete, "/jobs/2", "", http.StatusNotFound},
+		{http.MethodPut, "/jobs", "", http.StatusMethodNotAllowed},
+	} {
+		status, _ = adminRequest(t, invalid.method, server.URL+invalid.url, invalid.body)
+		require.Equal(t, invalid.status, status, "%s %s", invalid.method, invalid.url)
+	}
+}
+
+func Test_adminServer_cancelRunningJob(t *testing.T) {
+	running := make(chan struct{})
+	proceed := make(chan struct{})
+	var once sync.Once
+	server := newTestAdminServer(t, func(phase string, duration time.Duration) {
+		once.Do(func() {
+			close(running)
+			<-proceed
+		})
+	})
+
+	status, _ := adminRequest(t, http.MethodPost, server.URL+"/jobs", `{"path": ""}`)
+	require.Equal(t, http.StatusAccepted, status)
+	<-running
+	status, j := adminRequest(t, http.MethodDelete, server.URL+"/jobs/1", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, JOB_RUNNING, j.State)
+	close(proceed)
+
+	_, j = adminRequest(t, http.MethodGet, server.URL+"/jobs/1?wait=true", "")
+	require.Equal(t, JOB_CANCELED, j.State)
+}
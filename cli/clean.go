@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+func setupClean(flags *flag.FlagSet, opts *options) {
+	addConfigFlags(flags, opts)
+	addPathFlags(flags, opts)
+	flags.Var(&opts.Sizes, "size", "the thumbnails of this size are kept (required, except with -all). You can use this parameter more than once.")
+	flags.IntVar(&opts.CCSize, "cc-size", -1, "keeps the Chromecast file and the thumbnails of this size.")
+	flags.IntVar(&opts.FirstXMeta, "first-x-meta", -1, "if > 0, keeps the file '"+mfg.META_NAME_FIRST_X+"'.")
+	flags.IntVar(&opts.LastXMeta, "last-x-meta", -1, "if > 0, keeps the file '"+mfg.META_NAME_LAST_X+"'.")
+	flags.BoolVar(&opts.GeoJSON, "geojson", false, "keeps the geojson files.")
+	addLogFlags(flags, opts)
+	addLockFlags(flags, opts)
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "only prints the files, which would be removed.")
+	flags.BoolVar(&opts.cleanAll, "all", false, "removes all generated files: the thumbnails and the meta files.")
+}
+
+// Removes the generated files, which aren't needed anymore: the thumbnails of removed images or sizes, the meta files
+// of removed albums and the meta files, which aren't configured anymore. Empty folders are removed afterwards.
+func runClean(flags *flag.FlagSet, opts *options) {
+	requireFlags(flags, opts.ImagePath != "" && (len(opts.Sizes) > 0 || opts.cleanAll) && isValidLockMode(opts.lockMode))
+
+	err := opts.InitFileSystems()
+	mfg.CheckError(err, "Invalid options.")
+
+	if !opts.dryRun {
+		lock := lockGallery(opts)
+		defer lock.release()
+		releaseOnSignal(lock)
+	}
+
+	orphans, err := orphanedFiles(opts)
+	mfg.CheckError(err, "Can't read the generated files.")
+	if opts.dryRun {
+		for _, file := range orphans {
+			fmt.Println(file)
+		}
+		return
+	}
+
+	folders := make(map[string]bool)
+	for _, file := range orphans {
+		if err := opts.Output.Remove(file); err != nil {
+			log.WithField("file", file).WithError(err).Error("Can't remove file")
+			continue
+		}
+		fmt.Println(file)
+		for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+			folders[dir] = true
+		}
+	}
+	removeEmptyFolders(opts, folders)
+	log.WithField("files", len(orphans)).Info("Cleaned the gallery")
+}
+
+// Returns the generated files in the output, which aren't needed anymore. With -all, these are all generated files.
+func orphanedFiles(opts *options) ([]string, error) {
+	var orphans []string
+	err := fs.WalkDir(opts.Output, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name != "." && entry.Name() != mfg.THUMB_DIR && strings.HasPrefix(entry.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		dir := path.Dir(name)
+		if path.Base(dir) == mfg.THUMB_DIR {
+			if opts.cleanAll || !isNeededThumbnail(opts, path.Dir(dir), entry.Name()) {
+				orphans = append(orphans, name)
+			}
+		} else if generatedFiles[entry.Name()] {
+			if opts.cleanAll || !isNeededMetaFile(opts, dir, entry.Name()) {
+				orphans = append(orphans, name)
+			}
+		}
+		return nil
+	})
+	return orphans, err
+}
+
+// true, if the thumbnail has a configured size and the image still exists
+func isNeededThumbnail(opts *options, album string, thumbnail string) bool {
+	match := thumbnailPattern.FindStringSubmatch(thumbnail)
+	if match == nil {
+		return false
+	}
+	size, _ := strconv.Atoi(match[1])
+	if !containsSize(opts.ThumbnailSizes(), size) {
+		return false
+	}
+	info, err := fs.Stat(opts.Source, path.Join(album, match[2]))
+	return err == nil && !info.IsDir()
+}
+
+// true, if the album still exists and the meta file is configured
+func isNeededMetaFile(opts *options, album string, metaFile string) bool {
+	if info, err := fs.Stat(opts.Source, album); err != nil || !info.IsDir() {
+		return false
+	}
+	switch metaFile {
+	case mfg.META_NAME_CHROMECAST:
+		return opts.CCSize > 0
+	case mfg.META_NAME_FIRST_X:
+		return opts.FirstXMeta > 0
+	case mfg.META_NAME_LAST_X:
+		return opts.LastXMeta > 0
+	case mfg.META_NAME_GEOJSON:
+		return opts.GeoJSON
+	case mfg.META_NAME_GEOJSON_ALBUMS:
+		return opts.GeoJSON && album == "."
+	}
+	return true
+}
+
+// Removes the empty thumbnail folders and the empty album folders, the deepest first. If the output is the image path,
+// the folders of existing albums are kept, even if they are empty.
+func removeEmptyFolders(opts *options, folders map[string]bool) {
+	sorted := make([]string, 0, len(folders))
+	for folder := range folders {
+		sorted = append(sorted, folder)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.Count(sorted[i], "/") > strings.Count(sorted[j], "/")
+	})
+
+	for _, folder := range sorted {
+		if path.Base(folder) != mfg.THUMB_DIR && opts.OutputPath == opts.ImagePath {
+			if _, err := fs.Stat(opts.Source, folder); err == nil {
+				continue
+			}
+		}
+		entries, err := fs.ReadDir(opts.Output, folder)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := opts.Output.Remove(folder); err != nil && !os.IsNotExist(err) {
+			log.WithField("album", folder).WithError(err).Warn("Can't remove folder")
+		}
+	}
+}
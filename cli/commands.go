@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+)
+
+// the command, if the first argument is a flag
+const DEFAULT_COMMAND = "build"
+
+// A sub command of makeMeta with its own flags.
+type command struct {
+	name string
+	// the arguments after the flags, for the usage
+	args        string
+	description string
+	// registers the flags of the command
+	setup func(flags *flag.FlagSet, opts *options)
+	run   func(flags *flag.FlagSet, opts *options)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"build", "", "Creates the missing thumbnails and writes the meta files of the whole gallery or of a single album.",
+			setupBuild, runBuild},
+		{"watch", "", "Does a full build and keeps running: every changed album is rebuild and the admin api can start rebuilds.",
+			setupWatch, runWatch},
+		{"serve", "", "Serves the gallery over http and creates missing thumbnails on the first request.",
+			setupServe, runServe},
+		{"clean", "", "Removes the thumbnails of removed images or sizes and the generated files of removed albums.",
+			setupClean, runClean},
+		{"verify", "", "Checks that the meta files and the thumbnails are complete and up to date. Exits with 1, if not.",
+			setupVerify, runVerify},
+		{"stats", "", "Prints statistics about the images, the albums and the thumbnails.",
+			setupStats, runStats},
+		{"inspect", "<image>", "Prints what the generator reads from a single image: size, rotation, camera and time.",
+			setupInspect, runInspect},
+		{"print-config", "", "Prints the effective options of the config file, the profile and the flags.",
+			setupPrintConfig, runPrintConfig},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: makeMeta <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse 'makeMeta <command> -h' for the flags of a command. Without a command, %s is used.\n",
+		DEFAULT_COMMAND)
+}
+
+// Parses the flags and reads the config file. Exits on errors.
+func parseFlags(cmd command, args []string) (*flag.FlagSet, *options) {
+	opts := &options{}
+	opts.SizeQuality = make(map[int]int)
+	flags := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	flags.Usage = func() {
+		usage := strings.TrimSpace("makeMeta " + cmd.name + " [flags] " + cmd.args)
+		fmt.Fprintf(flags.Output(), "Usage: %s\n\n%s\n\nFlags:\n", usage, cmd.description)
+		flags.PrintDefaults()
+	}
+	cmd.setup(flags, opts)
+	flags.Parse(args)
+
+	if opts.config != "" {
+		err := loadConfig(flags, opts.config, opts.profile, opts.SizeQuality, knownConfigKeys())
+		mfg.CheckError(err, "Can't read the config file.")
+	} else if opts.profile != "" {
+		fmt.Fprintln(flags.Output(), "A profile needs a config file.")
+		os.Exit(2)
+	}
+	return flags, opts
+}
+
+// Exits with the usage of the command, if the condition isn't true.
+func requireFlags(flags *flag.FlagSet, condition bool) {
+	if !condition {
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// all flags, which can be set in the config file
+func knownConfigKeys() map[string]bool {
+	flags := flag.NewFlagSet("all", flag.ContinueOnError)
+	setupPrintConfig(flags, &options{})
+	known := make(map[string]bool)
+	flags.VisitAll(func(f *flag.Flag) {
+		known[f.Name] = true
+	})
+	return known
+}
+
+// the flag groups of the commands
+
+func addConfigFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.config, "config", "", "reads the options from this yaml file, the flags override the file.")
+	flags.StringVar(&opts.profile, "profile", "", "uses the options of this profile from the config file.")
+}
+
+func addPathFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.ImagePath, "path", "", "the path to the images (required)")
+	flags.StringVar(&opts.OutputPath, "output", "", "the thumbnails and meta files are in this folder (with the same album structure) instead of the image path.")
+}
+
+func addThumbnailFlags(flags *flag.FlagSet, opts *options, sizeUsage string) {
+	flags.Var(&opts.Sizes, "size", sizeUsage)
+	flags.IntVar(&opts.Quality, "quality", mfg.DEFAULT_QUALITY, "the jpeg quality of the thumbnails.")
+	flags.IntVar(&opts.MaxThreads, "max-threads", -1, "The maximum amount of thumbnails created at the same time. Default is the number of cpu.")
+}
+
+func addMetaFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.Order, "order", mfg.IMAGE_ORDER_FUNCTIONS[0], strings.Join(mfg.IMAGE_ORDER_FUNCTIONS[:], ","))
+	flags.IntVar(&opts.CCSize, "cc-size", -1, "creates a jsonp file for the Chromecast for this thumbnail size.")
+	flags.BoolVar(&opts.ForceUpdate, "force-update", false, "ignores the existing "+mfg.META_NAME+" files.")
+	flags.IntVar(&opts.FirstXMeta, "first-x-meta", -1, "if > 0, create the additional file '"+mfg.META_NAME_FIRST_X+"' with the first X images.")
+	flags.IntVar(&opts.LastXMeta, "last-x-meta", -1, "if > 0, create the additional file '"+mfg.META_NAME_LAST_X+"' with the last X images.")
+	flags.BoolVar(&opts.GeoJSON, "geojson", false, "creates the file '"+mfg.META_NAME_GEOJSON+"' with the geotagged images per album and '"+mfg.META_NAME_GEOJSON_ALBUMS+"' with all albums in the root.")
+	flags.StringVar(&opts.PlacesFile, "places", "", "adds the nearest place to the images with a gps position, read from this GeoNames cities file, e.g. cities15000.txt. The country names are read from the "+mfg.COUNTRY_INFO_NAME+" in the same folder.")
+	addTimeZoneFlag(flags, opts)
+	flags.Var(exifFieldsFlag{&opts.ExifFields}, "exif-fields", "the additional exif fields in the meta files, comma separated or 'all': "+strings.Join(mfg.EXIF_FIELDS[:], ","))
+}
+
+func addTimeZoneFlag(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.TimeZone, "time-zone", "", "the time zone of the images without utc offset in the exif, e.g. Europe/Berlin. A timeZone in the content.ini of a folder overrides it. The default is UTC.")
+}
+
+// a comma separated list of exif fields, "all" for all fields
+type exifFieldsFlag struct {
+	fields *[]string
+}
+
+func (f exifFieldsFlag) String() string {
+	if f.fields == nil {
+		return ""
+	}
+	return strings.Join(*f.fields, ",")
+}
+
+func (f exifFieldsFlag) Set(value string) error {
+	*f.fields = nil
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "all" {
+			*f.fields = append(*f.fields, mfg.EXIF_FIELDS[:]...)
+		} else if field != "" {
+			*f.fields = append(*f.fields, field)
+		}
+	}
+	return nil
+}
+
+func addLogFlags(flags *flag.FlagSet, opts *options) {
+	flags.BoolVar(&opts.debug, "debug", false, "activates debug logging and prints the data model.")
+	flags.StringVar(&opts.logLevel, "log-level", "info", "error,warn,info,debug")
+	flags.StringVar(&opts.logFormat, "log-format", mfg.LOG_FORMATS[0], strings.Join(mfg.LOG_FORMATS[:], ","))
+	flags.BoolVar(&opts.quiet, "quiet", false, "only logs errors.")
+}
+
+func addLockFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.lockMode, "lock-mode", LOCK_MODE_WAIT, "what to do, if another run works on the same path: "+strings.Join(LOCK_MODES[:], ","))
+	flags.DurationVar(&opts.lockStale, "lock-stale", 10*time.Minute, "a lock of a run on another host is stale, if it wasn't refreshed for this time.")
+}
+
+func addMetricsListenFlag(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.metricsListen, "metrics-listen", "", "serves the prometheus metrics on this address.")
+}
+
+func addFormatFlag(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.format, "format", "text", "the output format: text,json")
+}
+
+// print-config knows all flags, which can be set in the config file
+func setupPrintConfig(flags *flag.FlagSet, opts *options) {
+	setupBuild(flags, opts)
+	flags.DurationVar(&opts.watchDelay, "watch-delay", 5*time.Second, "the time without any file changes before an album is rebuild.")
+	flags.StringVar(&opts.adminListen, "admin-listen", "", "serves the admin api on this address or on 'unix:<socket file>'.")
+	addMetricsListenFlag(flags, opts)
+	flags.StringVar(&opts.listen, "listen", ":8080", "the address to listen on.")
+}
+
+func runPrintConfig(flags *flag.FlagSet, opts *options) {
+	err := printConfig(os.Stdout, flags, opts.SizeQuality)
+	mfg.CheckError(err, "Can't print the config.")
+}
+
+func isValidFormat(value string) bool {
+	return value == "text" || value == "json"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_selectCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantName string
+		wantArgs []string
+	}{
+		{[]string{"-path", "gallery"}, DEFAULT_COMMAND, []string{"-path", "gallery"}},
+		{[]string{"watch", "-path", "gallery"}, "watch", []string{"-path", "gallery"}},
+		{[]string{"inspect", "a.jpg"}, "inspect", []string{"a.jpg"}},
+		{[]string{}, DEFAULT_COMMAND, []string{}},
+	}
+	for _, tt := range tests {
+		name, args := selectCommand(tt.args)
+		require.Equal(t, tt.wantName, name, "%v", tt.args)
+		require.Equal(t, tt.wantArgs, args, "%v", tt.args)
+	}
+	_, found := findCommand("unknown")
+	require.False(t, found)
+}
+
+func Test_commands_flags(t *testing.T) {
+	tests := map[string][]string{
+		"build":        {"size", "dry-run", "subtree", "lock-mode", "handoff", "metrics-textfile"},
+		"watch":        {"size", "watch-delay", "watch-files", "admin-listen"},
+		"serve":        {"listen", "size", "lock-mode"},
+		"clean":        {"all", "dry-run"},
+		"verify":       {"size", "format"},
+		"stats":        {"format"},
+		"inspect":      {"format"},
+		"print-config": {"size", "listen", "watch-delay"},
+	}
+	require.Len(t, commands, len(tests))
+	for _, cmd := range commands {
+		flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		cmd.setup(flags, &options{})
+		for _, name := range tests[cmd.name] {
+			require.NotNil(t, flags.Lookup(name), "%s -%s", cmd.name, name)
+		}
+	}
+}
+
+func Test_build_verify(t *testing.T) {
+	gallery := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(gallery, "A"), 0755))
+	f, err := os.Create(filepath.Join(gallery, "A", "a.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil))
+	require.NoError(t, f.Close())
+
+	// the flags of the old versions are for the build
+	name, args := selectCommand([]string{"-path", gallery, "-size", "8"})
+	cmd, found := findCommand(name)
+	require.True(t, found)
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	opts := &options{}
+	cmd.setup(flags, opts)
+	require.NoError(t, flags.Parse(args))
+	cmd.run(flags, opts)
+
+	verifyOpts := &options{}
+	verifyOpts.ImagePath = gallery
+	verifyOpts.Sizes = mfg.IntList{8}
+	verifyOpts.ForceUpdate = true
+	require.NoError(t, verifyOpts.Validate())
+	content, err := mfg.ReadFolder(&verifyOpts.Options, ".")
+	require.NoError(t, err)
+	problems, err := verifyFolder(verifyOpts, content, []problem{})
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"gopkg.in/yaml.v2"
+)
+
+// the key of the named profiles in the config file
+const CONFIG_PROFILES = "profiles"
+
+// these flags select the config and can't be set in the file
+var configFlags = map[string]bool{"config": true, "profile": true}
+
+// Reads the yaml config file and sets all flags, which weren't given on the command line. The keys of the file are
+// the names of the flags. The options of the profile (in "profiles") override the options of the file. The size can
+// be a list of sizes or of {size, quality} to set the quality of single sizes (in sizeQuality).
+//
+//	size: [400, {size: 1600, quality: 90}]
+//	order: exifTimeDesc
+//	profiles:
+//	  archive:
+//	    size: [{size: 3000, quality: 95}]
+//
+// The file is shared by all commands: options in known, which the command doesn't have, are ignored.
+func loadConfig(flags *flag.FlagSet, file string, profile string, sizeQuality map[int]int, known map[string]bool) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", file, err)
+	}
+
+	settings := make(map[string]interface{})
+	for name, value := range config {
+		if name != CONFIG_PROFILES {
+			settings[name] = value
+		}
+	}
+	if profile != "" {
+		profiles, _ := config[CONFIG_PROFILES].(map[interface{}]interface{})
+		profileSettings, found := profiles[profile].(map[interface{}]interface{})
+		if !found {
+			return fmt.Errorf("unknown profile: %s", profile)
+		}
+		for name, value := range profileSettings {
+			settings[fmt.Sprint(name)] = value
+		}
+	}
+
+	setOnCommandLine := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] || configFlags[name] {
+			return fmt.Errorf("unknown option in the config file: %s", name)
+		}
+		if flags.Lookup(name) == nil || setOnCommandLine[name] {
+			continue
+		}
+		if err := setConfigValue(flags, name, settings[name], sizeQuality); err != nil {
+			return fmt.Errorf("invalid value for %s in the config file: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setConfigValue(flags *flag.FlagSet, name string, value interface{}, sizeQuality map[int]int) error {
+	values, isList := value.([]interface{})
+	if !isList {
+		values = []interface{}{value}
+	} else if _, repeatable := flags.Lookup(name).Value.(*mfg.IntList); !repeatable {
+		return errors.New("only one value allowed")
+	}
+
+	for _, v := range values {
+		if entry, isMap := v.(map[interface{}]interface{}); isMap {
+			if name != "size" {
+				return errors.New("only a single value allowed")
+			}
+			size, validSize := entry["size"].(int)
+			if !validSize {
+				return errors.New("size is missing")
+			}
+			if quality, found := entry["quality"]; found {
+				q, validQuality := quality.(int)
+				if !validQuality {
+					return fmt.Errorf("invalid quality: %v", quality)
+				}
+				sizeQuality[size] = q
+			}
+			v = size
+		}
+		if err := flags.Set(name, fmt.Sprint(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prints the effective options as config file.
+func printConfig(w io.Writer, flags *flag.FlagSet, sizeQuality map[int]int) error {
+	var config yaml.MapSlice
+	flags.VisitAll(func(f *flag.Flag) {
+		if configFlags[f.Name] {
+			return
+		}
+		var value interface{}
+		switch v := f.Value.(type) {
+		case *mfg.IntList:
+			var sizes []interface{}
+			for _, size := range *v {
+				if quality, found := sizeQuality[size]; found {
+					sizes = append(sizes, yaml.MapSlice{{Key: "size", Value: size}, {Key: "quality", Value: quality}})
+				} else {
+					sizes = append(sizes, size)
+				}
+			}
+			value = sizes
+		case flag.Getter:
+			value = v.Get()
+			if duration, isDuration := value.(time.Duration); isDuration {
+				value = duration.String()
+			}
+		default:
+			value = v.String()
+		}
+		config = append(config, yaml.MapItem{Key: f.Name, Value: value})
+	})
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
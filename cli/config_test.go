@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+size: [400, {size: 1600, quality: 90}]
+order: exifTimeDesc
+quality: 80
+listen: ":9000"
+profiles:
+  archive:
+    size: [{size: 3000, quality: 95}]
+    order: exifTimeAsc
+`
+
+// Returns the flags of the build command after the command line and the config file.
+func loadTestConfig(t *testing.T, config string, profile string, args ...string) (*options, error) {
+	file := filepath.Join(t.TempDir(), "makeMeta.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(config), 0644))
+
+	opts := &options{}
+	opts.SizeQuality = make(map[int]int)
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	setupBuild(flags, opts)
+	require.NoError(t, flags.Parse(args))
+	return opts, loadConfig(flags, file, profile, opts.SizeQuality, knownConfigKeys())
+}
+
+func Test_loadConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     string
+		args        []string
+		sizes       mfg.IntList
+		sizeQuality map[int]int
+		order       string
+		quality     int
+	}{
+		// the listen option of serve is ignored
+		{"file", "", nil, mfg.IntList{400, 1600}, map[int]int{1600: 90}, "exifTimeDesc", 80},
+		{"profile", "archive", nil, mfg.IntList{3000}, map[int]int{3000: 95}, "exifTimeAsc", 80},
+		{"flags", "", []string{"-size", "200", "-quality", "70"}, mfg.IntList{200}, map[int]int{}, "exifTimeDesc", 70},
+		{"flag over profile", "archive", []string{"-order", "filenameAsc"}, mfg.IntList{3000}, map[int]int{3000: 95},
+			"filenameAsc", 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := loadTestConfig(t, testConfig, tt.profile, tt.args...)
+			require.NoError(t, err)
+			require.Equal(t, tt.sizes, opts.Sizes)
+			require.Equal(t, tt.sizeQuality, opts.SizeQuality)
+			require.Equal(t, tt.order, opts.Order)
+			require.Equal(t, tt.quality, opts.Quality)
+		})
+	}
+}
+
+func Test_loadConfig_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+	}{
+		{"unknown option", "sizes: [400]", ""},
+		{"config option", "profile: archive", ""},
+		{"unknown profile", testConfig, "print"},
+		{"list of a single value", "order: [exifTimeDesc, filenameAsc]", ""},
+		{"size without size", "size: [{quality: 90}]", ""},
+		{"invalid quality", "size: [{size: 400, quality: high}]", ""},
+		{"invalid value", "quality: high", ""},
+		{"invalid yaml", "size: [400", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.config, tt.profile)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_printConfig(t *testing.T) {
+	opts := &options{}
+	opts.SizeQuality = map[int]int{1600: 90}
+	flags := flag.NewFlagSet("print-config", flag.ContinueOnError)
+	setupPrintConfig(flags, opts)
+	require.NoError(t, flags.Parse([]string{"-size", "400", "-size", "1600", "-lock-stale", "5m"}))
+
+	var buf bytes.Buffer
+	require.NoError(t, printConfig(&buf, flags, opts.SizeQuality))
+	require.Contains(t, buf.String(), "size:\n- 400\n- size: 1600\n  quality: 90\n")
+	require.Contains(t, buf.String(), "lock-stale: 5m0s\n")
+	require.NotContains(t, buf.String(), "profile")
+
+	// the printed config is a valid config file
+	loaded, err := loadTestConfig(t, buf.String(), "")
+	require.NoError(t, err)
+	require.Equal(t, mfg.IntList{400, 1600}, loaded.Sizes)
+	require.Equal(t, opts.SizeQuality, loaded.SizeQuality)
+	require.Equal(t, 5*time.Minute, loaded.lockStale)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	HOOK_EVENT_ALBUM = "album"
+	HOOK_EVENT_RUN   = "run"
+)
+
+// the commands and urls, which are called after a run
+type hookOptions struct {
+	albumCmd string
+	albumURL string
+	runCmd   string
+	runURL   string
+	timeout  time.Duration
+}
+
+// The payload of a hook: the json body of the webhooks and the stdin of the commands. All paths are relative to the
+// output, "" is the root album.
+type hookPayload struct {
+	Event string `json:"event"`
+	// only for the album event
+	Album *string `json:"album,omitempty"`
+	// only for the run event: all changed albums
+	Albums []string `json:"albums,omitempty"`
+	// the changed meta files and thumbnails
+	Files []string `json:"files"`
+}
+
+func addHookFlags(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.hooks.albumCmd, "hook-album-cmd", "", "runs this shell command for every changed album, the payload is on stdin.")
+	flags.StringVar(&opts.hooks.albumURL, "hook-album-url", "", "posts the payload of every changed album to this url.")
+	flags.StringVar(&opts.hooks.runCmd, "hook-run-cmd", "", "runs this shell command at the end of every run, the payload is on stdin.")
+	flags.StringVar(&opts.hooks.runURL, "hook-run-url", "", "posts the payload of every run to this url.")
+	flags.DurationVar(&opts.hooks.timeout, "hook-timeout", time.Minute, "the maximum time of a single hook.")
+}
+
+func (h *hookOptions) isEmpty() bool {
+	return h.albumCmd == "" && h.albumURL == "" && h.runCmd == "" && h.runURL == ""
+}
+
+// changeTracker is an OutputFS, which records the changed files of a run. A meta file with the same content isn't
+// recorded, but it's written anyway: the next run compares the content.ini, the captions.txt and the sidecars with its
+// modification time.
+type changeTracker struct {
+	mfg.OutputFS
+
+	mutex   sync.Mutex
+	changed map[string]bool
+}
+
+func newChangeTracker(out mfg.OutputFS) *changeTracker {
+	return &changeTracker{OutputFS: out, changed: make(map[string]bool)}
+}
+
+func (t *changeTracker) WriteFile(name string, data []byte) error {
+	old, err := fs.ReadFile(t.OutputFS, name)
+	unchanged := err == nil && bytes.Equal(old, data)
+	if err := t.OutputFS.WriteFile(name, data); err != nil {
+		return err
+	}
+	if !unchanged {
+		t.add(name)
+	}
+	return nil
+}
+
+func (t *changeTracker) Create(name string) (io.WriteCloser, error) {
+	w, err := t.OutputFS.Create(name)
+	if err == nil {
+		t.add(name)
+	}
+	return w, err
+}
+
+func (t *changeTracker) Remove(name string) error {
+	t.mutex.Lock()
+	delete(t.changed, name)
+	t.mutex.Unlock()
+	return t.OutputFS.Remove(name)
+}
+
+// records the file, if it's part of an album, e.g. not the journal
+func (t *changeTracker) add(name string) {
+	if strings.HasPrefix(path.Base(name), ".") {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.changed[name] = true
+}
+
+// Returns the sorted changed files per album.
+func (t *changeTracker) albums() map[string][]string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	albums := make(map[string][]string)
+	for file := range t.changed {
+		album := path.Dir(file)
+		if path.Base(album) == mfg.THUMB_DIR {
+			album = path.Dir(album)
+		}
+		if album == "." {
+			album = ""
+		}
+		albums[album] = append(albums[album], file)
+	}
+	for _, files := range albums {
+		sort.Strings(files)
+	}
+	return albums
+}
+
+// Calls the album hooks for every changed album and the run hooks once. All hooks are called, even if one fails,
+// the first error is returned.
+func (h *hookOptions) fire(changes *changeTracker) error {
+	if h.isEmpty() {
+		return nil
+	}
+	albums := changes.albums()
+	names := make([]string, 0, len(albums))
+	for album := range albums {
+		names = append(names, album)
+	}
+	sort.Strings(names)
+
+	var firstErr error
+	call := func(cmd string, url string, payload hookPayload) {
+		if err := h.call(cmd, url, payload); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	run := hookPayload{Event: HOOK_EVENT_RUN, Albums: names, Files: []string{}}
+	for i := range names {
+		files := albums[names[i]]
+		call(h.albumCmd, h.albumURL, hookPayload{Event: HOOK_EVENT_ALBUM, Album: &names[i], Files: files})
+		run.Files = append(run.Files, files...)
+	}
+	call(h.runCmd, h.runURL, run)
+	return firstErr
+}
+
+// calls the command and the url, if given
+func (h *hookOptions) call(cmd string, url string, payload hookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	logger := log.WithField("event", payload.Event)
+	if payload.Album != nil {
+		logger = logger.WithField("album", *payload.Album)
+	}
+
+	var firstErr error
+	if cmd != "" {
+		logger.WithField("cmd", cmd).Debug("Running hook command")
+		if err := h.runCommand(cmd, payload, data); err != nil {
+			logger.WithField("cmd", cmd).WithError(err).Error("Hook command failed")
+			firstErr = err
+		}
+	}
+	if url != "" {
+		logger.WithField("url", url).Debug("Calling webhook")
+		if err := h.post(url, data); err != nil {
+			logger.WithField("url", url).WithError(err).Error("Webhook failed")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Runs the command with "sh -c". The payload is on stdin, the event and the album are in MAKEMETA_EVENT and
+// MAKEMETA_ALBUM.
+func (h *hookOptions) runCommand(cmd string, payload hookPayload, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	command := exec.CommandContext(ctx, "sh", "-c", cmd)
+	command.Stdin = bytes.NewReader(data)
+	command.Env = append(os.Environ(), "MAKEMETA_EVENT="+payload.Event)
+	if payload.Album != nil {
+		command.Env = append(command.Env, "MAKEMETA_ALBUM="+*payload.Album)
+	}
+	output, err := command.CombinedOutput()
+	if output = bytes.TrimSpace(output); len(output) > 0 {
+		log.WithField("cmd", cmd).Debugf("Hook output:\n%s", output)
+	}
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%v: %s", err, output)
+	}
+	return err
+}
+
+// posts the payload as json, every status other than 2xx is an error
+func (h *hookOptions) post(url string, data []byte) error {
+	client := http.Client{Timeout: h.timeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("unexpected status: " + resp.Status)
+	}
+	return nil
+}
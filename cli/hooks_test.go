@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_changeTracker(t *testing.T) {
+	dir := t.TempDir()
+	out := mfg.NewDirFS(dir)
+	require.NoError(t, out.WriteFile("a/meta.json", []byte("old")))
+	lastRun := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a", "meta.json"), lastRun, lastRun))
+
+	changes := newChangeTracker(out)
+	require.NoError(t, changes.WriteFile("a/meta.json", []byte("old")))
+	require.NoError(t, changes.WriteFile("meta.json", []byte("new")))
+	w, err := changes.Create("a/b/.thumbs/100-x.jpg")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	w, err = changes.Create("a/.thumbs/100-failed.jpg")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, changes.Remove("a/.thumbs/100-failed.jpg"))
+
+	require.Equal(t, map[string][]string{
+		"":    {"meta.json"},
+		"a/b": {"a/b/.thumbs/100-x.jpg"},
+	}, changes.albums())
+
+	// the unchanged meta file is newer than the config files of the last run
+	info, err := os.Stat(filepath.Join(dir, "a", "meta.json"))
+	require.NoError(t, err)
+	require.True(t, info.ModTime().After(lastRun))
+}
+
+func Test_hookOptions_fire_webhook(t *testing.T) {
+	// asserted on the test goroutine
+	type request struct {
+		contentType string
+		body        []byte
+	}
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{r.Header.Get("Content-Type"), body}
+	}))
+	defer server.Close()
+
+	changes := newChangeTracker(mfg.NewDirFS(t.TempDir()))
+	require.NoError(t, changes.WriteFile("b/meta.json", []byte("{}")))
+	require.NoError(t, changes.WriteFile("a/meta.json", []byte("{}")))
+
+	hooks := hookOptions{albumURL: server.URL + "/album", runURL: server.URL + "/run", timeout: time.Second}
+	require.NoError(t, hooks.fire(changes))
+	close(requests)
+
+	var received []hookPayload
+	for r := range requests {
+		require.Equal(t, "application/json", r.contentType)
+		var payload hookPayload
+		require.NoError(t, json.Unmarshal(r.body, &payload))
+		received = append(received, payload)
+	}
+	a, b := "a", "b"
+	require.Equal(t, []hookPayload{
+		{Event: HOOK_EVENT_ALBUM, Album: &a, Files: []string{"a/meta.json"}},
+		{Event: HOOK_EVENT_ALBUM, Album: &b, Files: []string{"b/meta.json"}},
+		{Event: HOOK_EVENT_RUN, Albums: []string{"a", "b"}, Files: []string{"a/meta.json", "b/meta.json"}},
+	}, received)
+}
+
+func Test_hookOptions_fire_errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	changes := newChangeTracker(mfg.NewDirFS(t.TempDir()))
+	require.Error(t, (&hookOptions{runURL: server.URL, timeout: time.Second}).fire(changes))
+	require.Error(t, (&hookOptions{runCmd: "exit 3", timeout: time.Second}).fire(changes))
+}
+
+func Test_hookOptions_fire_command(t *testing.T) {
+	dir := t.TempDir()
+	changes := newChangeTracker(mfg.NewDirFS(dir))
+	require.NoError(t, changes.WriteFile("a/meta.json", []byte("{}")))
+
+	hooks := hookOptions{albumCmd: `cat > "` + dir + `/$MAKEMETA_EVENT-$MAKEMETA_ALBUM.json"`, timeout: time.Second}
+	require.NoError(t, hooks.fire(changes))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "album-a.json"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"event": "album", "album": "a", "files": ["a/meta.json"]}`, string(data))
+}
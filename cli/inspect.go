@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+)
+
+// the exif tags, which are used by the generator
+var INSPECT_TAGS = [...]string{"Make", "Model", "DateTimeOriginal", "DateTime", "OffsetTimeOriginal", "OffsetTime",
+	"SubSecTimeOriginal", "SubSecTime", "Orientation", "ExposureTime", "FNumber", "ISOSpeedRatings", "FocalLength",
+	"FocalLengthIn35mmFilm", "LensModel", "Flash", "WhiteBalance", "ExposureProgram", "Artist", "Copyright",
+	"GPSLatitude", "GPSLatitudeRef", "GPSLongitude", "GPSLongitudeRef", "GPSAltitude", "GPSAltitudeRef",
+	"GPSImgDirection", "BodySerialNumber"}
+
+var ROTATION_NAMES = map[mfg.RotationAction]string{
+	mfg.NO_ROTATION: "none",
+	mfg.ROTATE_90:   "90",
+	mfg.ROTATE_180:  "180",
+	mfg.ROTATE_270:  "270",
+}
+
+// what the generator reads from a single image
+type inspection struct {
+	File   string `json:"file"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// the rotation of the thumbnails
+	Rotation string     `json:"rotation"`
+	Make     *string    `json:"make"`
+	Model    *string    `json:"model"`
+	Time     *time.Time `json:"time"`
+	// the extended exif fields (see mfg.EXIF_FIELDS), the gps position and the xmp fields
+	Fields map[string]interface{} `json:"fields"`
+	// the raw exif tags
+	Tags map[string]string `json:"tags"`
+}
+
+func setupInspect(flags *flag.FlagSet, opts *options) {
+	addLogFlags(flags, opts)
+	addFormatFlag(flags, opts)
+	addTimeZoneFlag(flags, opts)
+	flags.BoolVar(&opts.inspectAll, "all", false, "prints all exif tags, not only the ones used by the generator.")
+}
+
+func runInspect(flags *flag.FlagSet, opts *options) {
+	requireFlags(flags, flags.NArg() == 1 && isValidFormat(opts.format))
+
+	file := flags.Arg(0)
+	source := mfg.NewDirFS(filepath.Dir(file))
+	name := filepath.Base(file)
+
+	var timeZone *time.Location
+	if opts.TimeZone != "" {
+		var err error
+		timeZone, err = time.LoadLocation(opts.TimeZone)
+		mfg.CheckError(err, "Unknown time zone.")
+	}
+	// with the content.ini of the folder like in the generator
+	info, err := mfg.ReadImageInfo(source, name, timeZone)
+	mfg.CheckError(err, "Can't read the image.")
+	result := inspection{
+		File:     file,
+		Width:    info.Width,
+		Height:   info.Height,
+		Rotation: ROTATION_NAMES[info.Rotate],
+		Make:     info.Exif.Make,
+		Model:    info.Exif.Model,
+		Tags:     make(map[string]string),
+	}
+	if info.Exif.Time != nil {
+		t := time.Unix(0, *info.Exif.Time*int64(time.Millisecond)).UTC()
+		// the local time of the camera, if the offset is known
+		if info.Exif.TimeOffset != nil {
+			if local, err := time.Parse(mfg.TIME_OFFSET_LAYOUT, *info.Exif.TimeOffset); err == nil {
+				t = t.In(local.Location())
+			}
+		}
+		result.Time = &t
+	}
+	data, err := json.Marshal(info.Exif)
+	mfg.CheckError(err)
+	mfg.CheckError(json.Unmarshal(data, &result.Fields))
+	for _, basic := range []string{"make", "model", "time", "timeOffset"} {
+		delete(result.Fields, basic)
+	}
+	// e.g. gps.latitude
+	if gps, ok := result.Fields["gps"].(map[string]interface{}); ok {
+		delete(result.Fields, "gps")
+		for name, value := range gps {
+			result.Fields["gps."+name] = value
+		}
+	}
+	var image map[string]interface{}
+	data, err = json.Marshal(info)
+	mfg.CheckError(err)
+	mfg.CheckError(json.Unmarshal(data, &image))
+	for _, field := range []string{"title", "description", "keywords", "rating", "label", "byline", "credit", "city", "country"} {
+		if value, found := image[field]; found {
+			result.Fields[field] = value
+		}
+	}
+
+	// a missing exif is already logged by ReadImageInfo
+	tags, err := mfg.ReadExifTags(source, name)
+	if err == nil {
+		for tag, value := range tags {
+			if opts.inspectAll || isInspectTag(tag) {
+				result.Tags[tag] = value
+			}
+		}
+	}
+
+	result.print(os.Stdout, opts.format)
+}
+
+func isInspectTag(tag string) bool {
+	for _, name := range INSPECT_TAGS {
+		if tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *inspection) print(w io.Writer, format string) {
+	if format == "json" {
+		data, err := json.MarshalIndent(i, "", "  ")
+		mfg.CheckError(err)
+		fmt.Fprintln(w, string(data))
+		return
+	}
+
+	orNone := func(value *string) string {
+		if value == nil {
+			return "-"
+		}
+		return *value
+	}
+	fmt.Fprintf(w, "file:     %s\n", i.File)
+	fmt.Fprintf(w, "size:     %dx%d\n", i.Width, i.Height)
+	fmt.Fprintf(w, "rotation: %s\n", i.Rotation)
+	fmt.Fprintf(w, "make:     %s\n", orNone(i.Make))
+	fmt.Fprintf(w, "model:    %s\n", orNone(i.Model))
+	if i.Time != nil {
+		fmt.Fprintf(w, "time:     %s\n", i.Time.Format(time.RFC3339Nano))
+	} else {
+		fmt.Fprintf(w, "time:     -\n")
+	}
+
+	fields := make([]string, 0, len(i.Fields))
+	for field := range i.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fmt.Fprintf(w, "%-9s %v\n", field+":", i.Fields[field])
+	}
+
+	names := make([]string, 0, len(i.Tags))
+	for name := range i.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		fmt.Fprintln(w, "exif:")
+	}
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-28s %s\n", name, i.Tags[name])
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	JOB_QUEUED   = "queued"
+	JOB_RUNNING  = "running"
+	JOB_DONE     = "done"
+	JOB_FAILED   = "failed"
+	JOB_CANCELED = "canceled"
+)
+
+// the amount of finished jobs, which are kept for the job list
+const FINISHED_JOBS_TO_KEEP = 100
+
+// A rebuild of an album subtree.
+type job struct {
+	Id int `json:"id"`
+	// relative to the image path, "" is the whole tree
+	Path            string     `json:"path"`
+	State           string     `json:"state"`
+	Created         time.Time  `json:"created"`
+	Started         *time.Time `json:"started"`
+	Finished        *time.Time `json:"finished"`
+	ThumbnailsDone  int        `json:"thumbnailsDone"`
+	ThumbnailsTotal int        `json:"thumbnailsTotal"`
+	Errors          []string   `json:"errors"`
+
+	fullPath string
+	cancel   context.CancelFunc
+	ctx      context.Context
+	// closed when the job is finished
+	done chan struct{}
+}
+
+// jobQueue runs the rebuild jobs one after another on a shared thumbnail pool.
+type jobQueue struct {
+	opts *options
+	pool *mfg.ThumbnailPool
+
+	mutex  sync.Mutex
+	nextId int
+	jobs   []*job
+	queue  chan *job
+}
+
+func newJobQueue(opts *options) *jobQueue {
+	q := &jobQueue{
+		opts:   opts,
+		pool:   mfg.NewThumbnailPool(opts.MaxThreads),
+		nextId: 1,
+		queue:  make(chan *job, 1000),
+	}
+	go q.run()
+	return q
+}
+
+// Adds a rebuild job for the album. albumPath is relative to the image path. If the album is already queued, the
+// existing job is returned.
+func (q *jobQueue) add(albumPath string) (*job, error) {
+	albumPath, err := mfg.CleanAlbumPath(albumPath)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, j := range q.jobs {
+		if j.Path == albumPath && j.State == JOB_QUEUED {
+			return j, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Id:       q.nextId,
+		Path:     albumPath,
+		State:    JOB_QUEUED,
+		Created:  time.Now(),
+		Errors:   []string{},
+		fullPath: path.Join(q.opts.ImagePath, albumPath),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	select {
+	case q.queue <- j:
+	default:
+		cancel()
+		return nil, errors.New("too many queued jobs")
+	}
+	q.nextId++
+	q.jobs = append(q.jobs, j)
+	log.WithFields(log.Fields{"job": j.Id, "album": j.fullPath}).Info("Rebuild queued")
+	return j, nil
+}
+
+// Returns a copy of the job, which is safe to use.
+func (q *jobQueue) get(id int) (job, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, j := range q.jobs {
+		if j.Id == id {
+			return j.snapshot(), true
+		}
+	}
+	return job{}, false
+}
+
+// Returns a copy of all jobs.
+func (q *jobQueue) list() []job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	list := make([]job, len(q.jobs))
+	for i, j := range q.jobs {
+		list[i] = j.snapshot()
+	}
+	return list
+}
+
+// Cancels a queued or running job. Returns false, if the job doesn't exist.
+func (q *jobQueue) cancel(id int) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, j := range q.jobs {
+		if j.Id == id {
+			j.cancel()
+			if j.State == JOB_QUEUED {
+				q.finish(j, JOB_CANCELED)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Waits until the job is finished or the context is done.
+func (q *jobQueue) wait(ctx context.Context, id int) {
+	q.mutex.Lock()
+	var done chan struct{}
+	for _, j := range q.jobs {
+		if j.Id == id {
+			done = j.done
+		}
+	}
+	q.mutex.Unlock()
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (q *jobQueue) run() {
+	for j := range q.queue {
+		q.runJob(j)
+	}
+}
+
+func (q *jobQueue) runJob(j *job) {
+	defer j.cancel()
+
+	q.mutex.Lock()
+	if j.State == JOB_CANCELED {
+		q.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	j.Started = &now
+	j.State = JOB_RUNNING
+	q.mutex.Unlock()
+
+	jobOpts := q.opts.Options
+	jobOpts.OnThumbnail = func(done int, total int, err error) {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		j.ThumbnailsDone = done
+		j.ThumbnailsTotal = total
+		if err != nil {
+			j.addError(err)
+		}
+	}
+	changes := newChangeTracker(jobOpts.Output)
+	jobOpts.Output = changes
+	builder := mfg.AlbumBuilder{Options: &jobOpts, Pool: q.pool, Out: jobOpts.Output}
+	err := builder.RebuildAlbum(j.ctx, j.Path)
+	hookErr := q.opts.hooks.fire(changes)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	switch {
+	case j.ctx.Err() != nil:
+		q.finish(j, JOB_CANCELED)
+	case err != nil || hookErr != nil:
+		// a failed thumbnail is already in the list
+		if err != nil {
+			j.addError(err)
+		}
+		if hookErr != nil {
+			j.addError(hookErr)
+		}
+		q.finish(j, JOB_FAILED)
+		mfg.CountFailure(mfg.FAILURE_RUN)
+	default:
+		q.finish(j, JOB_DONE)
+		lastSuccess.SetToCurrentTime()
+	}
+}
+
+// Sets the final state, wakes up the waiting requests and removes the oldest finished jobs. The mutex must be locked.
+func (q *jobQueue) finish(j *job, state string) {
+	now := time.Now()
+	j.Finished = &now
+	j.State = state
+	close(j.done)
+	logger := log.WithFields(log.Fields{"job": j.Id, "album": j.fullPath, "state": state})
+	if j.Started != nil {
+		logger = logger.WithField("duration", now.Sub(*j.Started).Seconds())
+	}
+	logger.Info("Job finished")
+
+	finished := 0
+	for i := len(q.jobs) - 1; i >= 0; i-- {
+		if q.jobs[i].Finished == nil {
+			continue
+		}
+		finished++
+		if finished > FINISHED_JOBS_TO_KEEP {
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+		}
+	}
+}
+
+// Adds the error, if it isn't in the list yet. The mutex must be locked.
+func (j *job) addError(err error) {
+	for _, e := range j.Errors {
+		if e == err.Error() {
+			return
+		}
+	}
+	j.Errors = append(j.Errors, err.Error())
+}
+
+// The mutex must be locked.
+func (j *job) snapshot() job {
+	c := *j
+	c.Errors = append([]string{}, j.Errors...)
+	return c
+}
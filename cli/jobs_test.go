@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a queue without runner, so its jobs stay queued.
+func newQueuedJobs() *jobQueue {
+	return &jobQueue{opts: &options{}, nextId: 1, queue: make(chan *job, 2*FINISHED_JOBS_TO_KEEP)}
+}
+
+func Test_jobQueue_add(t *testing.T) {
+	q := newQueuedJobs()
+	a, err := q.add("A/")
+	require.NoError(t, err)
+	require.Equal(t, "A", a.Path)
+	again, err := q.add("A")
+	require.NoError(t, err)
+	require.Equal(t, a.Id, again.Id)
+
+	_, err = q.add("../A")
+	require.Error(t, err)
+	require.Len(t, q.list(), 1)
+}
+
+func Test_jobQueue_history(t *testing.T) {
+	q := newQueuedJobs()
+	for i := 0; i < FINISHED_JOBS_TO_KEEP+5; i++ {
+		j, err := q.add(fmt.Sprintf("album%d", i))
+		require.NoError(t, err)
+		require.True(t, q.cancel(j.Id))
+	}
+	queued, err := q.add("queued")
+	require.NoError(t, err)
+
+	jobs := q.list()
+	require.Len(t, jobs, FINISHED_JOBS_TO_KEEP+1)
+	// the oldest finished jobs are removed
+	require.Equal(t, 6, jobs[0].Id)
+	require.Equal(t, JOB_CANCELED, jobs[0].State)
+	require.Equal(t, queued.Id, jobs[FINISHED_JOBS_TO_KEEP].Id)
+	require.False(t, q.cancel(1))
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LOCK_MODE_WAIT = "wait"
+	LOCK_MODE_SKIP = "skip"
+	LOCK_MODE_FAIL = "fail"
+)
+
+// available modes, if the gallery is locked by another run
+var LOCK_MODES = [...]string{LOCK_MODE_WAIT, LOCK_MODE_SKIP, LOCK_MODE_FAIL}
+
+const (
+	LOCK_POLL_INTERVAL = 5 * time.Second
+	// the modification time of the lock file is updated in this interval
+	LOCK_HEARTBEAT_INTERVAL = time.Minute
+)
+
+// The content of the lock file. An empty lock file is not locked.
+type lockInfo struct {
+	Pid     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+	// the admin api of the daemon, if any
+	AdminListen string `json:"adminListen,omitempty"`
+}
+
+// galleryLock is an advisory lock (flock) on a file in the output root, which prevents concurrent runs.
+type galleryLock struct {
+	file        *os.File
+	stop        chan struct{}
+	releaseOnce sync.Once
+}
+
+// the results of acquireLock, if the run doesn't happen here
+var (
+	errLockSkipped = errors.New("the gallery is locked by another run, skipping this run")
+	errLocked      = errors.New("the gallery is locked by another run")
+	errHandedOver  = errors.New("the daemon finished the run")
+)
+
+// Takes the lock of the gallery. If it's locked by another run, the run is handed over to the daemon (if requested
+// and possible) or the lock mode decides: wait for the lock, skip the run or fail. Exits the program, if the run
+// doesn't happen here.
+func lockGallery(opts *options) *galleryLock {
+	hostname, err := os.Hostname()
+	mfg.CheckError(err, "Can't get the hostname.")
+	own := lockInfo{Pid: os.Getpid(), Host: hostname, Started: time.Now(), AdminListen: opts.adminListen}
+	// a separate output could be new
+	err = os.MkdirAll(opts.OutputPath, 0755)
+	mfg.CheckError(err, "Can't create the output path.")
+
+	lock, err := acquireLock(opts, own, LOCK_POLL_INTERVAL)
+	switch err {
+	case errLockSkipped:
+		log.Info("Skipping this run.")
+		os.Exit(0)
+	case errHandedOver:
+		log.Info("The daemon finished the run.")
+		os.Exit(0)
+	}
+	mfg.CheckError(err, "Can't take the lock of the gallery.")
+	go lock.heartbeat()
+	// a fatal error must release the lock, too
+	log.RegisterExitHandler(lock.release)
+	return lock
+}
+
+// Takes the lock in the output path, see lockGallery. Returns errLockSkipped, errLocked or errHandedOver, if the run
+// doesn't happen here. The wait mode polls the lock in the given interval.
+func acquireLock(opts *options, own lockInfo, pollInterval time.Duration) (*galleryLock, error) {
+	lockFile := path.Join(opts.OutputPath, mfg.LOCK_NAME)
+	waiting := false
+	for {
+		lock, holder := tryLock(lockFile, own, opts.lockStale)
+		if lock != nil {
+			return lock, nil
+		}
+
+		logger := log.WithFields(log.Fields{"pid": holder.Pid, "host": holder.Host, "started": holder.Started})
+		if opts.handoff && holder.AdminListen != "" {
+			if handedOver, err := handoff(opts, own, holder); handedOver || err != nil {
+				if err == nil {
+					err = errHandedOver
+				}
+				return nil, err
+			}
+		}
+
+		switch opts.lockMode {
+		case LOCK_MODE_SKIP:
+			logger.Info("The gallery is locked by another run.")
+			return nil, errLockSkipped
+		case LOCK_MODE_FAIL:
+			logger.Info("The gallery is locked by another run.")
+			return nil, errLocked
+		default:
+			if !waiting {
+				logger.Info("The gallery is locked by another run, waiting...")
+				waiting = true
+			}
+			time.Sleep(pollInterval)
+		}
+	}
+}
+
+// Returns the lock or the info about the current holder.
+func tryLock(lockFile string, own lockInfo, staleAfter time.Duration) (*galleryLock, lockInfo) {
+	f, err := os.OpenFile(lockFile, os.O_RDWR|os.O_CREATE, 0644)
+	mfg.CheckError(err, "Can't open lock file.")
+
+	holder := readLockInfo(f)
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		// locked by a running process
+		f.Close()
+		return nil, holder
+	}
+
+	if holder.Pid != 0 {
+		// the file isn't empty, so the previous run didn't release the lock
+		info, err := f.Stat()
+		mfg.CheckError(err, "Can't read lock file.")
+		if holder.Host != own.Host && time.Since(info.ModTime()) < staleAfter {
+			// flock doesn't work across hosts on all network file systems, but the holder is still alive
+			f.Close()
+			return nil, holder
+		}
+		log.WithFields(log.Fields{"pid": holder.Pid, "host": holder.Host, "started": holder.Started}).
+			Warn("Taking over a stale lock.")
+	}
+
+	bytes, err := json.Marshal(own)
+	mfg.CheckError(err)
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt(bytes, 0)
+	}
+	mfg.CheckError(err, "Can't write lock file.")
+
+	return &galleryLock{file: f, stop: make(chan struct{})}, own
+}
+
+// reads the lock info, an empty info if the file is empty or invalid
+func readLockInfo(f *os.File) lockInfo {
+	var info lockInfo
+	if bytes, err := ioutil.ReadAll(f); err == nil && len(bytes) > 0 {
+		json.Unmarshal(bytes, &info)
+	}
+	return info
+}
+
+// updates the modification time of the lock file, so other hosts see that the lock isn't stale
+func (l *galleryLock) heartbeat() {
+	ticker := time.NewTicker(LOCK_HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			os.Chtimes(l.file.Name(), now, now)
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Releases the lock. The file is only emptied: removing it would allow a second lock on a new file, while another
+// process waits for the old one.
+func (l *galleryLock) release() {
+	l.releaseOnce.Do(func() {
+		close(l.stop)
+		l.file.Truncate(0)
+		syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+		l.file.Close()
+	})
+}
+
+// Hands the run over to the daemon, which holds the lock, and waits for the result. The daemon runs the job with its
+// own options, so it refuses the run, if the caller wants other thumbnails (sizes or qualities). Returns false without
+// error, if the run can't be handed over, and an error, if the daemon didn't finish the run.
+func handoff(opts *options, own lockInfo, holder lockInfo) (bool, error) {
+	client, baseUrl, ok := adminClient(own, holder)
+	if !ok {
+		return false, nil
+	}
+	logger := log.WithFields(log.Fields{"pid": holder.Pid, "host": holder.Host, "adminListen": holder.AdminListen})
+
+	request, _ := json.Marshal(jobRequest{Path: opts.subtree, Thumbnails: thumbnailOptions(&opts.Options)})
+	resp, err := client.Post(baseUrl+"/jobs", "application/json", bytes.NewReader(request))
+	if err != nil {
+		logger.WithError(err).Warn("Can't hand over the run to the daemon.")
+		return false, nil
+	}
+	if resp.StatusCode == http.StatusConflict {
+		resp.Body.Close()
+		logger.Warn("Can't hand over the run to the daemon, it creates other thumbnails.")
+		return false, nil
+	}
+	var j job
+	if err := readJobResponse(resp, &j); err != nil {
+		return false, fmt.Errorf("can't hand over the run to the daemon: %v", err)
+	}
+	logger.WithField("job", j.Id).Info("The run was handed over to the daemon, waiting for the result...")
+
+	resp, err = client.Get(fmt.Sprintf("%s/jobs/%d?wait=true", baseUrl, j.Id))
+	if err == nil {
+		err = readJobResponse(resp, &j)
+	}
+	if err != nil {
+		return false, fmt.Errorf("can't get the result from the daemon: %v", err)
+	}
+	if j.State != JOB_DONE {
+		return false, fmt.Errorf("the daemon couldn't finish the run, job %d is %s: %s", j.Id, j.State,
+			strings.Join(j.Errors, ", "))
+	}
+	return true, nil
+}
+
+// Returns the jpeg quality of every thumbnail size. A handoff needs the same thumbnails as the daemon.
+func thumbnailOptions(opts *mfg.Options) map[int]int {
+	thumbnails := make(map[int]int)
+	for _, size := range opts.ThumbnailSizes() {
+		thumbnails[size] = opts.ThumbnailQuality(size)
+	}
+	return thumbnails
+}
+
+// Returns a client for the admin api of the lock holder and its base url.
+func adminClient(own lockInfo, holder lockInfo) (*http.Client, string, bool) {
+	if strings.HasPrefix(holder.AdminListen, "unix:") {
+		if holder.Host != own.Host {
+			return nil, "", false
+		}
+		socket := strings.TrimPrefix(holder.AdminListen, "unix:")
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+		return &http.Client{Transport: transport}, "http://makeMeta", true
+	}
+
+	host, port, err := net.SplitHostPort(holder.AdminListen)
+	if err != nil {
+		return nil, "", false
+	}
+	if host == "" {
+		host = "localhost"
+		if holder.Host != own.Host {
+			host = holder.Host
+		}
+	}
+	return http.DefaultClient, "http://" + net.JoinHostPort(host, port), true
+}
+
+func readJobResponse(resp *http.Response, j *job) error {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, j)
+}
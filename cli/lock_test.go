@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns the options of a run on the gallery and the lock of another run, which holds it.
+func lockedGallery(t *testing.T, adminListen string) (*options, *galleryLock) {
+	opts := &options{lockMode: LOCK_MODE_WAIT, lockStale: time.Hour}
+	opts.OutputPath = t.TempDir()
+	opts.Sizes = mfg.IntList{100}
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	holder, _ := tryLock(filepath.Join(opts.OutputPath, mfg.LOCK_NAME),
+		lockInfo{Pid: 1, Host: hostname, Started: time.Now(), AdminListen: adminListen}, opts.lockStale)
+	require.NotNil(t, holder)
+	t.Cleanup(holder.release)
+	return opts, holder
+}
+
+func ownLockInfo(t *testing.T) lockInfo {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	return lockInfo{Pid: os.Getpid(), Host: hostname, Started: time.Now()}
+}
+
+func Test_acquireLock_modes(t *testing.T) {
+	opts, holder := lockedGallery(t, "")
+	opts.lockMode = LOCK_MODE_SKIP
+	_, err := acquireLock(opts, ownLockInfo(t), time.Millisecond)
+	require.Equal(t, errLockSkipped, err)
+
+	opts.lockMode = LOCK_MODE_FAIL
+	_, err = acquireLock(opts, ownLockInfo(t), time.Millisecond)
+	require.Equal(t, errLocked, err)
+
+	opts.lockMode = LOCK_MODE_WAIT
+	time.AfterFunc(50*time.Millisecond, holder.release)
+	lock, err := acquireLock(opts, ownLockInfo(t), time.Millisecond)
+	require.NoError(t, err)
+	lock.release()
+}
+
+func Test_tryLock_stale(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), mfg.LOCK_NAME)
+	other, err := json.Marshal(lockInfo{Pid: 1, Host: "other-host", Started: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(lockFile, other, 0644))
+
+	// the run on the other host refreshed the lock file recently
+	lock, holder := tryLock(lockFile, ownLockInfo(t), time.Minute)
+	require.Nil(t, lock)
+	require.Equal(t, "other-host", holder.Host)
+
+	old := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, os.Chtimes(lockFile, old, old))
+	lock, holder = tryLock(lockFile, ownLockInfo(t), time.Minute)
+	require.NotNil(t, lock)
+	require.Equal(t, os.Getpid(), holder.Pid)
+	lock.release()
+}
+
+func Test_acquireLock_handoff(t *testing.T) {
+	requests := make(chan jobRequest, 10)
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var request jobRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			requests <- request
+			if request.Thumbnails[100] != mfg.DEFAULT_QUALITY {
+				http.Error(w, "other thumbnails", http.StatusConflict)
+				return
+			}
+			writeJson(w, http.StatusAccepted, job{Id: 1, State: JOB_QUEUED})
+			return
+		}
+		if r.URL.Path != "/jobs/1" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJson(w, http.StatusOK, job{Id: 1, State: JOB_DONE})
+	}))
+	defer daemon.Close()
+
+	opts, _ := lockedGallery(t, strings.TrimPrefix(daemon.URL, "http://"))
+	opts.handoff = true
+	opts.lockMode = LOCK_MODE_SKIP
+	opts.subtree = "album"
+	_, err := acquireLock(opts, ownLockInfo(t), time.Millisecond)
+	require.Equal(t, errHandedOver, err)
+	require.Equal(t, jobRequest{Path: "album", Thumbnails: map[int]int{100: mfg.DEFAULT_QUALITY}}, <-requests)
+
+	// the daemon refuses other thumbnails, the lock mode decides
+	opts.Quality = 50
+	_, err = acquireLock(opts, ownLockInfo(t), time.Millisecond)
+	require.Equal(t, errLockSkipped, err)
+	require.Equal(t, map[int]int{100: 50}, (<-requests).Thumbnails)
+}
@@ -1,422 +1,259 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
+	"os/signal"
 	"path"
-	"regexp"
-	"strconv"
 	"strings"
-
-	"image"
-
-	"sort"
+	"syscall"
 	"time"
+	// the time zones of -time-zone and the content.ini for the static binary
+	_ "time/tzdata"
 
-	"math"
-
-	"errors"
-
-	"github.com/go-ini/ini"
 	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
-	"github.com/xor-gate/goexif2/exif"
-	"github.com/xor-gate/goexif2/tiff"
+	log "github.com/sirupsen/logrus"
 )
 
-var filePattern = regexp.MustCompile(mfg.FILE_REGEXP)
-
-// folder date pattern
-var ymdPattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})_(.*)$`)
-var ymPattern = regexp.MustCompile(`^(\d{4})-(\d{2})_(.*)$`)
-var yPattern = regexp.MustCompile(`^(\d{4})_(.*)$`)
-
-func main() {
-	imagePathPtr := flag.String("path", "", "the path to the images (required)")
-	var sizes mfg.IntList
-	flag.Var(&sizes, "size", "the bounding box of the thumbnails (required). You can use this parameter more than once.")
-	orderPtr := flag.String("order", mfg.IMAGE_ORDER_FUNCTIONS[0], strings.Join(mfg.IMAGE_ORDER_FUNCTIONS[:], ","))
-	ccSizePtr := flag.Int("cc-size", -1, "creates a jsonp file for the Chromecast for this thumbnail size.")
-	forceUpdatePtr := flag.Bool("force-update", false, "ignores the existing "+mfg.META_NAME+" files.")
-	maxThreads := flag.Int("max-threads", -1, "The maximum amount of threads to use. Default is the number of cpu.")
-	firstXMeta := flag.Int("first-x-meta", -1, "if > 0, create the additional file '"+mfg.META_NAME_FIRST_X+"' with the first X images.")
-	lastXMeta := flag.Int("last-x-meta", -1, "if > 0, create the additional file '"+mfg.META_NAME_LAST_X+"' with the last X images.")
-	debug := flag.Bool("debug", false, "activates debug logging.")
-
-	flag.Parse()
-
-	if *imagePathPtr == "" || len(sizes) == 0 || !isValidOrder(*orderPtr) {
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	// add the requested size for the Chromecast to size slice
-	sizes = addSizeIfNeeded(sizes, *ccSizePtr)
-
-	checkSizes(sizes)
-
-	log.Printf("Reading '%s' for images...", *imagePathPtr)
-	content := readFolder(*imagePathPtr, *forceUpdatePtr)
-
-	updateImageMetaInfos(content)
-	if *debug {
-		log.Printf("Data model:\n%s\n", content)
-	}
-	mfg.UpdateThumbnails(content, sizes, *maxThreads)
-	writeMetaFiles(content, *orderPtr, *ccSizePtr, *firstXMeta, *lastXMeta)
+// the options of the generator and of the cli
+type options struct {
+	mfg.Options
+	debug      bool
+	logLevel   string
+	logFormat  string
+	quiet      bool
+	dryRun     bool
+	planFormat string
+	// clean and inspect
+	cleanAll   bool
+	inspectAll bool
+	requireAlt bool
+	// the output format of verify, stats and inspect
+	format      string
+	watchDelay  time.Duration
+	watchFiles  bool
+	adminListen string
+	// the address of the gallery server
+	listen string
+	// metrics
+	metricsListen   string
+	metricsTextfile string
+	// concurrent runs
+	lockMode  string
+	lockStale time.Duration
+	handoff   bool
+	// relative to the image path
+	subtree string
+	hooks   hookOptions
+	// the config file
+	config  string
+	profile string
 }
 
-func checkSizes(sizes mfg.IntList) {
-	for _, size := range sizes {
-		if size <= 0 {
-			log.Fatal("Invalid size: ", size)
+func main() {
+	args := os.Args[1:]
+	var name string
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		if len(args) > 1 {
+			if cmd, found := findCommand(args[1]); found {
+				flags, _ := parseFlags(cmd, []string{})
+				flags.Usage()
+				return
+			}
 		}
-	}
-}
-
-func addSizeIfNeeded(sizeList mfg.IntList, ccSize int) mfg.IntList {
-	if ccSize == -1 {
-		return sizeList
-	}
-	for _, size := range sizeList {
-		if size == ccSize {
-			return sizeList
+		printUsage()
+		if len(args) == 0 {
+			os.Exit(2)
 		}
-	}
-
-	return append(sizeList, ccSize)
+		return
+	}
+	name, args = selectCommand(args)
+	cmd, found := findCommand(name)
+	if !found {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+	flags, opts := parseFlags(cmd, args)
+	setupLogging(opts.logLevel, opts.logFormat, opts.debug, opts.quiet)
+	cmd.run(flags, opts)
 }
 
-func readFolder(folder string, forceUpdate bool) *mfg.FolderContent {
-	content := mfg.FolderContent{FullPath: folder, Name: path.Base(folder)}
-	content.ImageMetadata = make(map[string]mfg.MetaJsonImage)
-
-	files, err := ioutil.ReadDir(folder)
-	mfg.CheckError(err)
-	for _, file := range files {
-		// skip .xxxx folder/files
-		if strings.HasPrefix(file.Name(), ".") {
-			continue
-		}
-
-		var fullPath = content.GetFullPathFile(file.Name())
-		if file.IsDir() {
-			content.Folder = append(content.Folder, *readFolder(fullPath, forceUpdate))
-			//content.AddSubFolder(*readFolder(fullPath, forceUpdate))
-			continue
-		}
-
-		if file.Name() == mfg.CONTENT_INI {
-			log.Println("Content INI file found in ", folder)
-			content.Config = readIniFile(fullPath)
-			continue
-		}
-
-		if !forceUpdate && file.Name() == mfg.META_NAME {
-			log.Println("Previous generated meta file found in ", folder)
-			readPrevImageInfos(content.ImageMetadata, fullPath)
-			continue
-		}
-
-		if !filePattern.MatchString(file.Name()) {
-			continue
-		}
-
-		content.Files = append(content.Files, file.Name())
+// Returns the name of the command and its arguments. Without a command, the flags are for the build, like in the old
+// versions.
+func selectCommand(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
 	}
+	return DEFAULT_COMMAND, args
+}
 
-	return &content
+func setupBuild(flags *flag.FlagSet, opts *options) {
+	addConfigFlags(flags, opts)
+	addPathFlags(flags, opts)
+	addThumbnailFlags(flags, opts, "the bounding box of the thumbnails (required). You can use this parameter more than once.")
+	addMetaFlags(flags, opts)
+	addLogFlags(flags, opts)
+	addLockFlags(flags, opts)
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "only prints what would be done, nothing is written.")
+	flags.StringVar(&opts.planFormat, "plan-format", "text", "the output format of the dry run: text,json")
+	flags.StringVar(&opts.metricsTextfile, "metrics-textfile", "", "writes the prometheus metrics to this file after the run, for the textfile collector of the node exporter.")
+	flags.StringVar(&opts.subtree, "subtree", "", "only processes this album (relative to the path) and updates its entry in the meta files of the parent folders.")
+	flags.BoolVar(&opts.handoff, "handoff", false, "hands the run over to the running watch daemon, instead of waiting for its lock.")
+	addHookFlags(flags, opts)
 }
 
-// reads recursively all meta data, if needed
-func updateImageMetaInfos(folder *mfg.FolderContent) {
-	var newestTime int64 = math.MinInt64
-	for _, imgFile := range folder.Files {
-		imgMeta, exists := folder.ImageMetadata[imgFile]
-		if !exists {
-			imgMeta = readImageInfo(imgFile, folder.GetFullPathFile(imgFile))
-			folder.ImageMetadata[imgFile] = imgMeta
-		}
+func runBuild(flags *flag.FlagSet, opts *options) {
+	requireFlags(flags, opts.ImagePath != "" && len(opts.Sizes) > 0 && isValidFormat(opts.planFormat) &&
+		isValidLockMode(opts.lockMode))
 
-		if imgMeta.Exif.Time != nil && *imgMeta.Exif.Time > newestTime {
-			newestTime = *imgMeta.Exif.Time
-		}
-	}
+	err := opts.Validate()
+	mfg.CheckError(err, "Invalid options.")
+	opts.OnThumbnail = logThumbnailError
 
-	title, timestamp, ok := parseTitleAndDateFromFoldername(folder.Name)
-	folder.Title = strings.Replace(title, "_", " ", -1)
+	opts.subtree, err = mfg.CleanAlbumPath(opts.subtree)
+	mfg.CheckError(err, "Invalid subtree.")
 
-	if ok {
-		fTime := timestamp.UnixNano() / 1000 / 1000
-		folder.Time = &fTime
-	} else if newestTime != math.MinInt64 {
-		folder.Time = &newestTime
+	if opts.dryRun {
+		printPlan(opts)
+		return
 	}
 
-	for i := range folder.Folder {
-		updateImageMetaInfos(&folder.Folder[i])
-		// update the folder time if any sub folder has a newer time
-		if folder.Time == nil || (folder.Folder[i].Time != nil && *folder.Time < *folder.Folder[i].Time) {
-			folder.Time = folder.Folder[i].Time
-		}
-	}
+	lock := lockGallery(opts)
+	defer lock.release()
+	releaseOnSignal(lock)
+
+	message, err := buildWithMetrics(opts)
+	mfg.CheckError(err, message)
 }
 
-func writeMetaFiles(folder *mfg.FolderContent, imageOrderFunction string, ccSize int, firstXMeta int, lastXMeta int) {
-	log.Println("Writing meta file for ", folder.Name)
-	meta := mfg.MetaJson{}
-	meta.Images = make([]mfg.MetaJsonImage, len(folder.Files))
-	for i, imgFile := range folder.Files {
-		imgMeta, found := folder.ImageMetadata[imgFile]
-		if !found {
-			log.Fatal("Expected to find '", imgMeta, "' in imageMetadata. Folder: ", folder.Name)
-		}
-		meta.Images[i] = imgMeta
+// Does the build and writes the metrics textfile, a failed run, too. A failed run keeps the last success of the
+// previous textfile.
+func buildWithMetrics(opts *options) (string, error) {
+	if opts.metricsTextfile != "" {
+		loadLastSuccess(opts.metricsTextfile)
 	}
-
-	mfg.SortImages(imageOrderFunction, meta.Images)
-
-	meta.Meta.Title = folder.GetFolderTitle()
-	meta.Meta.Description = folder.Config.Description
-
-	meta.SubDirs = make([]mfg.MetaJsonSubDir, len(folder.Folder))
-	for i := range folder.Folder {
-		subFolder := &folder.Folder[i]
-		sub := &meta.SubDirs[i]
-		sub.FolderName = subFolder.Name
-		sub.Title = subFolder.GetFolderTitle()
-		sub.Time = subFolder.Time
-		sub.ImageCount = sumFolderImageCount(subFolder)
-		if len(subFolder.Config.Cover) > 0 {
-			sub.Cover = &subFolder.Config.Cover
-		} else if len(subFolder.Files) > 0 {
-			sub.Cover = &subFolder.Files[0]
-		}
-
-		writeMetaFiles(subFolder, imageOrderFunction, ccSize, firstXMeta, lastXMeta)
+	message, err := build(opts)
+	if opts.metricsTextfile != "" {
+		writeMetricsTextfile(opts.metricsTextfile)
 	}
+	return message, err
+}
 
-	// all sub dirs are read -> sets the time
-	meta.Meta.Time = folder.Time // default
-
-	sort.Sort(mfg.ByTimeDesc{meta.SubDirs})
-
-	writeAsJson(meta, path.Join(folder.FullPath, mfg.META_NAME))
-
-	if ccSize != -1 {
-		writeChromecastMetaFile(ccSize, meta.Images, folder)
+// The full run or the run of the subtree, the hooks are called at the end. Returns the error with its log message.
+func build(opts *options) (string, error) {
+	runOpts := opts.Options
+	changes := newChangeTracker(runOpts.Output)
+	runOpts.Output = changes
+
+	var err error
+	message := "Can't generate the gallery."
+	if opts.subtree != "" {
+		err = rebuildSubtree(&runOpts, opts.subtree)
+		message = "Can't rebuild the subtree."
+	} else {
+		err = mfg.Generate(context.Background(), &runOpts)
+	}
+	hookErr := opts.hooks.fire(changes)
+	if err != nil {
+		mfg.CountFailure(mfg.FAILURE_RUN)
+		return message, err
 	}
-
-	if firstXMeta > 0 {
-		end := firstXMeta
-		if end > len(meta.Images) {
-			end = len(meta.Images)
-		}
-		firstXMeta := mfg.MetaJson{
-			Meta:    meta.Meta,
-			SubDirs: meta.SubDirs,
-			Images:  meta.Images[0:end],
-		}
-		writeAsJson(firstXMeta, path.Join(folder.FullPath, mfg.META_NAME_FIRST_X))
+	if hookErr != nil {
+		mfg.CountFailure(mfg.FAILURE_RUN)
+		return "A hook failed.", hookErr
 	}
+	lastSuccess.SetToCurrentTime()
+	return "", nil
+}
 
-	if lastXMeta > 0 {
-		start := len(meta.Images) - lastXMeta
-		if start < 0 {
-			start = 0
-		}
-		lastXMeta := mfg.MetaJson{
-			Meta:    meta.Meta,
-			SubDirs: meta.SubDirs,
-			Images:  meta.Images[start:],
-		}
-		writeAsJson(lastXMeta, path.Join(folder.FullPath, mfg.META_NAME_LAST_X))
+// the thumbnail progress of the runs: the run goes on, if a thumbnail fails
+func logThumbnailError(done int, total int, err error) {
+	if err != nil {
+		log.WithError(err).Error("Can't create thumbnail")
 	}
 }
 
-func writeAsJson(jsonData interface{}, target string) {
-	bytes, err := json.Marshal(jsonData)
-	mfg.CheckError(err, "Can't write json file.")
-	_ = ioutil.WriteFile(target, bytes, 0644)
-}
+// Processes only the subtree and updates its entry in the meta files of the parent folders.
+func rebuildSubtree(opts *mfg.Options, subtree string) error {
+	pool := mfg.NewThumbnailPool(opts.MaxThreads)
+	defer pool.Close()
 
-// calculates the amount of photos of this folder inclusive all images in sub folders
-func sumFolderImageCount(folder *mfg.FolderContent) int {
-	sum := len(folder.Files)
-	for _, sub := range folder.Folder {
-		sum += sumFolderImageCount(&sub)
-	}
-	return sum
+	builder := mfg.AlbumBuilder{Options: opts, Pool: pool, Out: opts.Output}
+	return builder.RebuildAlbum(context.Background(), subtree)
 }
 
-func writeChromecastMetaFile(ccSize int, images []mfg.MetaJsonImage, folder *mfg.FolderContent) {
-	log.Println("Writing Chromecast meta file for ", folder.Name)
-	var ccImages = make([]mfg.ChromecastImage, len(images))
-	for i, image := range images {
-		filename := fmt.Sprintf("%s/%d-%s", mfg.THUMB_DIR, ccSize, image.Filename)
-		ccImages[i] = mfg.ChromecastImage{filename, image.Width, image.Height, image.Exif.Time}
-	}
-
-	ccFilename := folder.FullPath + "/" + mfg.META_NAME_CHROMECAST
-	bytes, err := json.Marshal(ccImages)
-	mfg.CheckError(err, "Can't write Chromecast meta file.")
-	f, err := os.OpenFile(ccFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	mfg.CheckError(err)
-	defer f.Close()
-	f.Write([]byte(mfg.CC_PREFIX))
-	f.Write(bytes)
-	f.Write([]byte(mfg.CC_SUFFIX))
+// prints what a run would do, nothing is written
+func printPlan(opts *options) {
+	albumPath := path.Join(opts.ImagePath, opts.subtree)
+	log.WithField("path", albumPath).Info("Reading the folders for images...")
+	p, message, err := makePlan(opts)
+	mfg.CheckError(err, message)
+	p.print(os.Stdout, opts.planFormat)
 }
 
-func parseTitleAndDateFromFoldername(filename string) (string, time.Time, bool) {
-	result := ymdPattern.FindStringSubmatch(filename)
-	if len(result) > 0 {
-		year, _ := strconv.Atoi(result[1])
-		month, _ := strconv.Atoi(result[2])
-		day, _ := strconv.Atoi(result[3])
-		return result[4], time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), true
+// Collects what the run would do, see plan. Returns the error with its log message.
+func makePlan(opts *options) (*plan, string, error) {
+	content, err := mfg.ReadFolder(&opts.Options, path.Join(".", opts.subtree))
+	if err != nil {
+		return nil, "Can't read the folders.", err
 	}
 
-	result = ymPattern.FindStringSubmatch(filename)
-	if len(result) > 0 {
-		year, _ := strconv.Atoi(result[1])
-		month, _ := strconv.Atoi(result[2])
-		return result[3], time.Date(year, time.Month(month), 0, 0, 0, 0, 0, time.UTC), true
+	p := newPlan(opts.Output)
+	p.addMissingMetadata(content)
+	if err := mfg.UpdateImageMetaInfos(&opts.Options, content); err != nil {
+		return nil, "Can't read the metadata.", err
 	}
-
-	result = yPattern.FindStringSubmatch(filename)
-	if len(result) > 0 {
-		year, _ := strconv.Atoi(result[1])
-		return result[2], time.Date(year, 0, 0, 0, 0, 0, 0, time.UTC), true
+	if err := p.addMissingThumbnails(&opts.Options, content); err != nil {
+		return nil, "Can't check the thumbnails.", err
 	}
-
-	return filename, time.Time{}, false
-}
-
-func readImageInfo(filename, input string) mfg.MetaJsonImage {
-	log.Println("Read image meta info from ", input)
-
-	f, err := os.Open(input)
-	mfg.CheckError(err)
-	defer f.Close()
-
-	imageConfig, _, err := image.DecodeConfig(f)
-	mfg.CheckError(err)
-	imageMeta := mfg.MetaJsonImage{Filename: filename, Width: imageConfig.Width, Height: imageConfig.Height}
-
-	// reset the file pointer
-	f.Seek(0, 0)
-
-	x, err := exif.Decode(f)
-	if err != nil && exif.IsCriticalError(err) {
-		log.Println("Warn: can't read exif. ", err)
-		return imageMeta
+	err = mfg.WriteMetaFiles(content, &opts.Options, p)
+	builder := mfg.AlbumBuilder{Options: &opts.Options, Out: p}
+	if err == nil && opts.subtree != "" {
+		entry := mfg.NewSubDirEntry(content)
+		err = builder.UpdateAncestors(context.Background(), opts.subtree, &entry)
 	}
-
-	if camModel, err := x.Get(exif.Model); err == nil {
-		if model, err := camModel.StringVal(); err == nil {
-			model = strings.TrimSpace(model)
-			imageMeta.Exif.Model = &model
-		}
+	if err == nil {
+		// the albums are read from the previous meta files
+		err = builder.WriteGeoJsonIndex()
 	}
-
-	if camMaker, err := x.Get(exif.Make); err == nil {
-		if maker, err := camMaker.StringVal(); err == nil {
-			maker = strings.TrimSpace(maker)
-			imageMeta.Exif.Make = &maker
-		}
+	if err != nil {
+		return nil, "Can't plan the meta files.", err
 	}
+	return p, "", nil
+}
 
-	if datetime, err := getExifTime(x); err == nil {
-		timeInMS := datetime.UnixNano() / 1000 / 1000
-		imageMeta.Exif.Time = &timeInMS
+// Configures the logger from the flags, exits on invalid values. debug and quiet win over the level.
+func setupLogging(level string, format string, debug bool, quiet bool) {
+	if debug {
+		level = "debug"
 	}
-
-	imageMeta.Rotate = mfg.NO_ROTATION
-	if orientation, err := x.Get(exif.Orientation); err == nil {
-		if orientationVal, err := orientation.Int(0); err == nil {
-			// http://jpegclub.org/exif_orientation.html
-			if orientationVal == 3 || orientationVal == 4 {
-				imageMeta.Rotate = mfg.ROTATE_180
-			} else if orientationVal == 5 || orientationVal == 6 {
-				imageMeta.Rotate = mfg.ROTATE_270
-				imageMeta.Width, imageMeta.Height = imageMeta.Height, imageMeta.Width
-			} else if orientationVal == 7 || orientationVal == 8 {
-				imageMeta.Rotate = mfg.ROTATE_90
-				imageMeta.Width, imageMeta.Height = imageMeta.Height, imageMeta.Width
-			}
-		}
+	if quiet {
+		level = "error"
 	}
-
-	return imageMeta
+	err := mfg.SetupLogging(level, format)
+	mfg.CheckError(err, "Invalid log settings.")
 }
 
-// from the exif package (exif.DateTime), but I use the UTC location as default (instead of the time.Local)
-func getExifTime(x *exif.Exif) (time.Time, error) {
-	var dt time.Time
-	tag, err := x.Get(exif.DateTimeOriginal)
-	if err != nil {
-		tag, err = x.Get(exif.DateTime)
-		if err != nil {
-			return dt, err
-		}
-	}
-	if tag.Format() != tiff.StringVal {
-		return dt, errors.New("DateTime[Original] not in string format")
-	}
-	exifTimeLayout := "2006:01:02 15:04:05"
-	dateStr := strings.TrimRight(string(tag.Val), "\x00")
-	timeZone := time.UTC
-	if tz, _ := x.TimeZone(); tz != nil {
-		fmt.Println("tz found: ", tz)
-		timeZone = tz
-	}
-	return time.ParseInLocation(exifTimeLayout, dateStr, timeZone)
+// releases the lock, if the program is stopped, e.g. in watch mode
+func releaseOnSignal(lock *galleryLock) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.WithField("signal", sig).Info("Stopping.")
+		lock.release()
+		os.Exit(1)
+	}()
 }
 
-func isValidOrder(value string) bool {
-	for i := 0; i < len(mfg.IMAGE_ORDER_FUNCTIONS); i++ {
-		if value == mfg.IMAGE_ORDER_FUNCTIONS[i] {
+func isValidLockMode(value string) bool {
+	for _, mode := range LOCK_MODES {
+		if value == mode {
 			return true
 		}
 	}
 	return false
 }
-
-func readIniFile(iniFile string) mfg.FolderConfig {
-	cfg, err := ini.Load(iniFile)
-	mfg.CheckError(err, "Error reading ini file.", iniFile)
-	section, err := cfg.GetSection("")
-	mfg.CheckError(err, "Error reading section file.", iniFile)
-
-	config := mfg.FolderConfig{}
-	title, err := section.GetKey("title")
-	if err == nil {
-		config.Title = title.Value()
-	}
-	description, err := section.GetKey("description")
-	if err == nil {
-		config.Description = description.Value()
-	}
-	cover, err := section.GetKey("cover")
-	if err == nil {
-		config.Cover = cover.Value()
-	}
-
-	return config
-}
-
-func readPrevImageInfos(metaMap map[string]mfg.MetaJsonImage, jsonFile string) {
-	bytes, err := ioutil.ReadFile(jsonFile)
-	mfg.CheckError(err, "Error reading json file.")
-
-	var jsonContent mfg.MetaJson
-	err = json.Unmarshal(bytes, &jsonContent)
-	mfg.CheckError(err, "Invalid json in file.")
-	for _, imgInfo := range jsonContent.Images {
-		metaMap[imgInfo.Filename] = imgInfo
-	}
-}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+var lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: mfg.METRICS_NAMESPACE,
+	Name:      "last_success_timestamp_seconds",
+	Help:      "The time of the last successful run or rebuild job.",
+})
+
+func init() {
+	mfg.MetricsRegistry.MustRegister(lastSuccess)
+}
+
+// Starts the metrics endpoint (/metrics) in the background.
+func startMetricsServer(listen string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(mfg.MetricsRegistry, promhttp.HandlerOpts{}))
+	log.WithField("listen", listen).Info("Metrics server started")
+	go func() {
+		err := http.ListenAndServe(listen, mux)
+		mfg.CheckError(err, "Metrics server stopped.")
+	}()
+}
+
+// Sets the last success to the value in the metrics textfile of the previous run, if there is one.
+func loadLastSuccess(file string) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	prefix := mfg.METRICS_NAMESPACE + "_last_success_timestamp_seconds "
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			if value, err := strconv.ParseFloat(strings.TrimPrefix(line, prefix), 64); err == nil {
+				lastSuccess.Set(value)
+			}
+		}
+	}
+}
+
+// Writes all metrics to the file for the textfile collector of the node exporter.
+func writeMetricsTextfile(file string) {
+	err := prometheus.WriteToTextfile(file, mfg.MetricsRegistry)
+	mfg.CheckError(err, "Can't write metrics file", file)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_buildWithMetrics_failedRun(t *testing.T) {
+	gallery := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(gallery, mfg.CONTENT_INI), []byte("gps=maybe"), 0644))
+	textfile := filepath.Join(t.TempDir(), "makeMeta.prom")
+	lastSuccessMetric := mfg.METRICS_NAMESPACE + "_last_success_timestamp_seconds 1.6e+09"
+	require.NoError(t, ioutil.WriteFile(textfile, []byte(lastSuccessMetric+"\n"), 0644))
+
+	opts := &options{metricsTextfile: textfile}
+	opts.ImagePath = gallery
+	opts.Sizes = mfg.IntList{100}
+	require.NoError(t, opts.Validate())
+
+	_, err := buildWithMetrics(opts)
+	require.Error(t, err)
+	metrics, err := ioutil.ReadFile(textfile)
+	require.NoError(t, err)
+	require.Contains(t, string(metrics), lastSuccessMetric)
+	for _, phase := range []string{mfg.PHASE_SCAN, mfg.FAILURE_RUN} {
+		require.NotContains(t, string(metrics), mfg.METRICS_NAMESPACE+`_failures_total{phase="`+phase+`"} 0`)
+		require.Contains(t, string(metrics), mfg.METRICS_NAMESPACE+`_failures_total{phase="`+phase+`"}`)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+)
+
+// plan collects everything a run would do without touching the disk. It is used for the dry run.
+type plan struct {
+	Albums []*albumPlan `json:"albums"`
+	byPath map[string]*albumPlan
+	// the output with the existing meta files
+	out fs.FS
+}
+
+type albumPlan struct {
+	Path        string        `json:"path"`
+	MetaFiles   []plannedFile `json:"metaFiles"`
+	Thumbnails  []string      `json:"thumbnails"`
+	NewMetadata []string      `json:"newMetadata"`
+}
+
+type plannedFile struct {
+	File string `json:"file"`
+	// "create" or "update"
+	Action string `json:"action"`
+}
+
+func newPlan(out fs.FS) *plan {
+	return &plan{Albums: []*albumPlan{}, byPath: make(map[string]*albumPlan), out: out}
+}
+
+func (p *plan) album(albumPath string) *albumPlan {
+	album, exists := p.byPath[albumPath]
+	if !exists {
+		album = &albumPlan{Path: albumPath, MetaFiles: []plannedFile{}, Thumbnails: []string{}, NewMetadata: []string{}}
+		p.byPath[albumPath] = album
+		p.Albums = append(p.Albums, album)
+	}
+	return album
+}
+
+// collects recursively all images without metadata. Must be called before mfg.UpdateImageMetaInfos.
+func (p *plan) addMissingMetadata(folder *mfg.FolderContent) {
+	for _, imgFile := range folder.Files {
+		if _, exists := folder.ImageMetadata[imgFile]; !exists {
+			album := p.album(folder.FullPath)
+			album.NewMetadata = append(album.NewMetadata, imgFile)
+		}
+	}
+
+	for i := range folder.Folder {
+		p.addMissingMetadata(&folder.Folder[i])
+	}
+}
+
+// collects recursively all thumbnails which would be created
+func (p *plan) addMissingThumbnails(opts *mfg.Options, folder *mfg.FolderContent) error {
+	targets, err := mfg.MissingThumbnails(opts, folder)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		album := p.album(folder.FullPath)
+		album.Thumbnails = append(album.Thumbnails, path.Join(mfg.THUMB_DIR, path.Base(target)))
+	}
+
+	for i := range folder.Folder {
+		if err := p.addMissingThumbnails(opts, &folder.Folder[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile records the meta file, if it doesn't exist or has a different content. Implements mfg.MetaWriter.
+func (p *plan) WriteFile(target string, data []byte) error {
+	action := "create"
+	prev, err := fs.ReadFile(p.out, target)
+	if err == nil {
+		if bytes.Equal(prev, data) {
+			return nil
+		}
+		action = "update"
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	album := p.album(path.Dir(target))
+	album.MetaFiles = append(album.MetaFiles, plannedFile{path.Base(target), action})
+	return nil
+}
+
+func (p *plan) print(w io.Writer, format string) {
+	if format == "json" {
+		bytes, err := json.MarshalIndent(p, "", "  ")
+		mfg.CheckError(err, "Can't create json plan.")
+		fmt.Fprintln(w, string(bytes))
+		return
+	}
+
+	var metaFiles, thumbnails, newMetadata int
+	for _, album := range p.Albums {
+		fmt.Fprintf(w, "%s\n", album.Path)
+		for _, file := range album.MetaFiles {
+			fmt.Fprintf(w, "    %-8s %s\n", file.Action, file.File)
+		}
+		for _, file := range album.NewMetadata {
+			fmt.Fprintf(w, "    %-8s %s\n", "metadata", file)
+		}
+		for _, file := range album.Thumbnails {
+			fmt.Fprintf(w, "    %-8s %s\n", "thumb", file)
+		}
+		metaFiles += len(album.MetaFiles)
+		thumbnails += len(album.Thumbnails)
+		newMetadata += len(album.NewMetadata)
+	}
+	fmt.Fprintf(w, "%d meta files to write, %d images to read metadata from, %d thumbnails to create.\n",
+		metaFiles, newMetadata, thumbnails)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+// memOutput is an in-memory output.
+type memOutput struct {
+	fstest.MapFS
+}
+
+type memFile struct {
+	bytes.Buffer
+	name string
+	out  memOutput
+}
+
+func (f *memFile) Close() error {
+	return f.out.WriteFile(f.name, f.Bytes())
+}
+
+func (m memOutput) WriteFile(name string, data []byte) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data}
+	return nil
+}
+
+func (m memOutput) Create(name string) (io.WriteCloser, error) {
+	return &memFile{name: name, out: m}, nil
+}
+
+func (m memOutput) Remove(name string) error {
+	delete(m.MapFS, name)
+	return nil
+}
+
+func testJpeg(t *testing.T) *fstest.MapFile {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil))
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+func Test_makePlan(t *testing.T) {
+	source := fstest.MapFS{
+		"changed/a.jpg":   testJpeg(t),
+		"removed/b.jpg":   testJpeg(t),
+		"unchanged/c.jpg": testJpeg(t),
+	}
+	out := memOutput{fstest.MapFS{}}
+	opts := &options{}
+	opts.Source = source
+	opts.Output = out
+	opts.Sizes = mfg.IntList{8}
+	require.NoError(t, mfg.Generate(context.Background(), &opts.Options))
+
+	delete(source, "removed/b.jpg")
+	delete(source, "changed/a.jpg")
+	source["changed/a2.jpg"] = testJpeg(t)
+	source["new/d.jpg"] = testJpeg(t)
+	delete(out.MapFS, "unchanged/"+mfg.THUMB_DIR+"/8-c.jpg")
+
+	p, _, err := makePlan(opts)
+	require.NoError(t, err)
+	albums := make(map[string]albumPlan)
+	for _, album := range p.Albums {
+		albums[album.Path] = *album
+	}
+	require.Len(t, albums, 4)
+
+	// the entries of the sub albums changed
+	require.Contains(t, albums["."].MetaFiles, plannedFile{mfg.META_NAME, "update"})
+	require.Equal(t, []string{"a2.jpg"}, albums["changed"].NewMetadata)
+	require.Equal(t, []string{mfg.THUMB_DIR + "/8-a2.jpg"}, albums["changed"].Thumbnails)
+	require.Contains(t, albums["changed"].MetaFiles, plannedFile{mfg.META_NAME, "update"})
+	require.Equal(t, []string{"d.jpg"}, albums["new"].NewMetadata)
+	require.Equal(t, []string{mfg.THUMB_DIR + "/8-d.jpg"}, albums["new"].Thumbnails)
+	require.Contains(t, albums["new"].MetaFiles, plannedFile{mfg.META_NAME, "create"})
+	// only the removed thumbnail is created again
+	require.Empty(t, albums["unchanged"].NewMetadata)
+	require.Equal(t, []string{mfg.THUMB_DIR + "/8-c.jpg"}, albums["unchanged"].Thumbnails)
+	require.Empty(t, albums["unchanged"].MetaFiles)
+	// nothing is written
+	require.NotContains(t, out.MapFS, "new/"+mfg.META_NAME)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+// the name of a thumbnail: <size>-<image file>
+var thumbnailPattern = regexp.MustCompile(`^(\d+)-(.+)$`)
+
+// the generated meta files, which are served besides the thumbnails and the images
+var SERVED_META_FILES = [...]string{mfg.META_NAME, mfg.META_NAME_CHROMECAST, mfg.META_NAME_FIRST_X, mfg.META_NAME_LAST_X,
+	mfg.META_NAME_GEOJSON, mfg.META_NAME_GEOJSON_ALBUMS}
+
+// galleryServer serves the meta files, the thumbnails and the images of the gallery and creates missing thumbnails on
+// the first request. A file is served from the output, if it exists there, otherwise from the images.
+type galleryServer struct {
+	source mfg.DirFS
+	output mfg.DirFS
+	// the sizes and the jpeg quality of the thumbnails
+	opts *mfg.Options
+	// limits the amount of thumbnails created at the same time
+	workers chan struct{}
+
+	mutex sync.Mutex
+	// thumbnails, which are created right now. The channel is closed when the thumbnail is done.
+	inProgress map[string]chan struct{}
+}
+
+func setupServe(flags *flag.FlagSet, opts *options) {
+	addConfigFlags(flags, opts)
+	addPathFlags(flags, opts)
+	addThumbnailFlags(flags, opts, "missing thumbnails of this size are created on request. You can use this parameter more than once.")
+	addLogFlags(flags, opts)
+	addLockFlags(flags, opts)
+	addMetricsListenFlag(flags, opts)
+	flags.StringVar(&opts.listen, "listen", ":8080", "the address to listen on.")
+	flags.StringVar(&opts.adminListen, "admin-listen", "", "serves the admin api on this address or on 'unix:<socket file>', other runs can hand over to it.")
+}
+
+func runServe(flags *flag.FlagSet, opts *options) {
+	requireFlags(flags, opts.ImagePath != "" && isValidLockMode(opts.lockMode))
+
+	err := opts.InitFileSystems()
+	mfg.CheckError(err, "Invalid options.")
+	checkSizes(opts.Sizes)
+	if opts.Quality < 1 || opts.Quality > 100 {
+		log.WithField("quality", opts.Quality).Fatal("Invalid quality")
+	}
+	if opts.adminListen != "" {
+		// the rebuild jobs need all options
+		err = opts.Validate()
+		mfg.CheckError(err, "Invalid options.")
+		opts.OnThumbnail = logThumbnailError
+	}
+
+	if opts.metricsListen != "" {
+		startMetricsServer(opts.metricsListen)
+	}
+
+	// the thumbnails are written into the output like in a build
+	lock := lockGallery(opts)
+	defer lock.release()
+	releaseOnSignal(lock)
+	if opts.adminListen != "" {
+		startAdminServer(opts.adminListen, newJobQueue(opts))
+	}
+
+	server := newGalleryServer(&opts.Options)
+	log.WithFields(log.Fields{"path": opts.ImagePath, "listen": opts.listen}).Info("Serving the gallery")
+	err = http.ListenAndServe(opts.listen, server)
+	mfg.CheckError(err, "Can't start the server.")
+}
+
+func newGalleryServer(opts *mfg.Options) *galleryServer {
+	maxThreads := opts.MaxThreads
+	if maxThreads <= 0 {
+		maxThreads = runtime.NumCPU()
+	}
+	return &galleryServer{
+		source:     mfg.NewDirFS(opts.ImagePath),
+		output:     mfg.NewDirFS(opts.OutputPath),
+		opts:       opts,
+		workers:    make(chan struct{}, maxThreads),
+		inProgress: make(map[string]chan struct{}),
+	}
+}
+
+func (s *galleryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	urlPath := path.Clean("/" + r.URL.Path)
+	if !isPublicPath(urlPath) || !isServedFile(urlPath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := strings.TrimPrefix(urlPath, "/")
+	if path.Base(path.Dir(urlPath)) == mfg.THUMB_DIR {
+		status := s.ensureThumbnail(name)
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+
+	f, err := os.Open(s.output.LocalPath(name))
+	if os.IsNotExist(err) {
+		f, err = os.Open(s.source.LocalPath(name))
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	// handles Last-Modified, the conditional requests and ranges
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// Makes sure the thumbnail (relative to the root) exists, it's created if needed. Returns the http status.
+func (s *galleryServer) ensureThumbnail(thumbnail string) int {
+	// wait, if somebody else creates the thumbnail right now
+	s.mutex.Lock()
+	if done, exists := s.inProgress[thumbnail]; exists {
+		s.mutex.Unlock()
+		<-done
+		return s.thumbnailStatus(thumbnail)
+	}
+	if _, err := fs.Stat(s.output, thumbnail); err == nil {
+		s.mutex.Unlock()
+		return http.StatusOK
+	}
+
+	match := thumbnailPattern.FindStringSubmatch(path.Base(thumbnail))
+	if match == nil || !mfg.IsImageFile(match[2]) {
+		s.mutex.Unlock()
+		return http.StatusNotFound
+	}
+	size, _ := strconv.Atoi(match[1])
+	if !containsSize(s.opts.Sizes, size) {
+		s.mutex.Unlock()
+		return http.StatusNotFound
+	}
+	input := path.Join(path.Dir(path.Dir(thumbnail)), match[2])
+	if info, err := fs.Stat(s.source, input); err != nil || info.IsDir() {
+		s.mutex.Unlock()
+		return http.StatusNotFound
+	}
+
+	done := make(chan struct{})
+	s.inProgress[thumbnail] = done
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.inProgress, thumbnail)
+		s.mutex.Unlock()
+		close(done)
+	}()
+
+	s.workers <- struct{}{}
+	defer func() { <-s.workers }()
+
+	rotation := mfg.ReadRotation(s.source, input)
+	if err := mfg.CreateThumbnail(s.source, s.output, input, thumbnail, size, s.opts.ThumbnailQuality(size), rotation); err != nil {
+		log.WithFields(log.Fields{"file": input, "size": size}).WithError(err).Error("Can't create thumbnail")
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+func (s *galleryServer) thumbnailStatus(thumbnail string) int {
+	if _, err := fs.Stat(s.output, thumbnail); err != nil {
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+// Only the thumbnail folder is public from all .xxxx folder/files.
+func isPublicPath(urlPath string) bool {
+	for _, part := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(part, ".") && part != mfg.THUMB_DIR {
+			return false
+		}
+	}
+	return true
+}
+
+// Only the meta files, the thumbnails and the images are served. The content.ini, the captions, the sidecars and the
+// tracks are private, e.g. the tracks have the exact positions.
+func isServedFile(urlPath string) bool {
+	if path.Base(path.Dir(urlPath)) == mfg.THUMB_DIR || mfg.IsImageFile(path.Base(urlPath)) {
+		return true
+	}
+	for _, name := range SERVED_META_FILES {
+		if path.Base(urlPath) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSize(sizeList mfg.IntList, size int) bool {
+	for _, s := range sizeList {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+func checkSizes(sizes mfg.IntList) {
+	for _, size := range sizes {
+		if size <= 0 {
+			log.WithField("size", size).Fatal("Invalid size")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_galleryServer_sizeQuality(t *testing.T) {
+	gallery := t.TempDir()
+	f, err := os.Create(filepath.Join(gallery, "a.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, jpeg.Encode(f, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil))
+	require.NoError(t, f.Close())
+
+	opts := mfg.Options{ImagePath: gallery, Sizes: mfg.IntList{8}, Quality: 95, SizeQuality: map[int]int{8: 10}}
+	require.NoError(t, opts.InitFileSystems())
+	response := httptest.NewRecorder()
+	newGalleryServer(&opts).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/"+mfg.THUMB_DIR+"/8-a.jpg", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+
+	// like the generator creates it
+	reference := mfg.NewDirFS(t.TempDir())
+	require.NoError(t, mfg.CreateThumbnail(opts.Source, reference, "a.jpg", "8-a.jpg", 8, 10, mfg.NO_ROTATION))
+	expected, err := ioutil.ReadFile(reference.LocalPath("8-a.jpg"))
+	require.NoError(t, err)
+	require.Equal(t, expected, response.Body.Bytes())
+}
+
+func Test_galleryServer_privateFiles(t *testing.T) {
+	gallery := t.TempDir()
+	for name, data := range map[string]string{
+		"a.jpg": "jpeg", mfg.META_NAME: "{}", mfg.CONTENT_INI: "gps=true", "hike.gpx": "<gpx/>", "a.xmp": "<x/>",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(gallery, name), []byte(data), 0644))
+	}
+
+	opts := mfg.Options{ImagePath: gallery, Sizes: mfg.IntList{8}}
+	require.NoError(t, opts.InitFileSystems())
+	server := newGalleryServer(&opts)
+	for name, status := range map[string]int{
+		"a.jpg": http.StatusOK, mfg.META_NAME: http.StatusOK,
+		mfg.CONTENT_INI: http.StatusNotFound, "hike.gpx": http.StatusNotFound, "a.xmp": http.StatusNotFound,
+	} {
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/"+name, nil))
+		require.Equal(t, status, response.Code, name)
+	}
+}
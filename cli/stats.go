@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+)
+
+// the statistics of a gallery. The images and albums are counted in the source, the rest comes from the output.
+type galleryStats struct {
+	Albums     int   `json:"albums"`
+	Images     int   `json:"images"`
+	ImageBytes int64 `json:"imageBytes"`
+	// per size
+	Thumbnails     map[int]int   `json:"thumbnails"`
+	ThumbnailBytes map[int]int64 `json:"thumbnailBytes"`
+	ImagesWithTime int           `json:"imagesWithTime"`
+	ImagesNoTime   int           `json:"imagesWithoutTime"`
+	Oldest         *time.Time    `json:"oldest,omitempty"`
+	Newest         *time.Time    `json:"newest,omitempty"`
+	// "make model" -> images
+	Cameras map[string]int `json:"cameras"`
+}
+
+func setupStats(flags *flag.FlagSet, opts *options) {
+	addConfigFlags(flags, opts)
+	addPathFlags(flags, opts)
+	addLogFlags(flags, opts)
+	addFormatFlag(flags, opts)
+}
+
+func runStats(flags *flag.FlagSet, opts *options) {
+	requireFlags(flags, opts.ImagePath != "" && isValidFormat(opts.format))
+
+	err := opts.InitFileSystems()
+	mfg.CheckError(err, "Invalid options.")
+
+	stats := galleryStats{Thumbnails: make(map[int]int), ThumbnailBytes: make(map[int]int64), Cameras: make(map[string]int)}
+	err = stats.addSource(opts.Source)
+	mfg.CheckError(err, "Can't read the images.")
+	err = stats.addOutput(opts.Output)
+	mfg.CheckError(err, "Can't read the generated files.")
+	stats.print(os.Stdout, opts.format)
+}
+
+// counts the albums and images of the source
+func (s *galleryStats) addSource(source fs.FS) error {
+	return walkGallery(source, func(name string, entry fs.DirEntry) error {
+		if entry.IsDir() {
+			s.Albums++
+			return nil
+		}
+		if path.Base(path.Dir(name)) == mfg.THUMB_DIR || !mfg.IsImageFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		s.Images++
+		s.ImageBytes += info.Size()
+		return nil
+	})
+}
+
+// counts the thumbnails and reads the image metadata of the meta files
+func (s *galleryStats) addOutput(output fs.FS) error {
+	return walkGallery(output, func(name string, entry fs.DirEntry) error {
+		if entry.IsDir() {
+			return nil
+		}
+		if path.Base(path.Dir(name)) == mfg.THUMB_DIR {
+			match := thumbnailPattern.FindStringSubmatch(entry.Name())
+			if match == nil {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size, _ := strconv.Atoi(match[1])
+			s.Thumbnails[size]++
+			s.ThumbnailBytes[size] += info.Size()
+			return nil
+		}
+		if entry.Name() != mfg.META_NAME {
+			return nil
+		}
+
+		meta, err := mfg.ReadMetaJson(output, name)
+		if err != nil {
+			return err
+		}
+		for _, img := range meta.Images {
+			if img.Exif.Time == nil {
+				s.ImagesNoTime++
+			} else {
+				s.ImagesWithTime++
+				t := time.Unix(0, *img.Exif.Time*int64(time.Millisecond)).UTC()
+				if s.Oldest == nil || t.Before(*s.Oldest) {
+					s.Oldest = &t
+				}
+				if s.Newest == nil || t.After(*s.Newest) {
+					s.Newest = &t
+				}
+			}
+			s.Cameras[cameraName(img)]++
+		}
+		return nil
+	})
+}
+
+// Walks the gallery without the .xxxx folder/files, the thumbnail folders are included.
+func walkGallery(fsys fs.FS, fn func(name string, entry fs.DirEntry) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && entry.Name() != mfg.THUMB_DIR && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() && entry.Name() == mfg.THUMB_DIR {
+			// only the thumbnails themselves are interesting
+			return nil
+		}
+		return fn(name, entry)
+	})
+}
+
+func cameraName(img mfg.MetaJsonImage) string {
+	var parts []string
+	if img.Exif.Make != nil && *img.Exif.Make != "" {
+		parts = append(parts, *img.Exif.Make)
+	}
+	if img.Exif.Model != nil && *img.Exif.Model != "" {
+		parts = append(parts, *img.Exif.Model)
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *galleryStats) print(w io.Writer, format string) {
+	if format == "json" {
+		data, err := json.MarshalIndent(s, "", "  ")
+		mfg.CheckError(err)
+		fmt.Fprintln(w, string(data))
+		return
+	}
+
+	fmt.Fprintf(w, "albums:             %d\n", s.Albums)
+	fmt.Fprintf(w, "images:             %d (%s)\n", s.Images, formatBytes(s.ImageBytes))
+	sizes := make([]int, 0, len(s.Thumbnails))
+	for size := range s.Thumbnails {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		fmt.Fprintf(w, "thumbnails %-8d %d (%s)\n", size, s.Thumbnails[size], formatBytes(s.ThumbnailBytes[size]))
+	}
+	fmt.Fprintf(w, "with time:          %d\n", s.ImagesWithTime)
+	fmt.Fprintf(w, "without time:       %d\n", s.ImagesNoTime)
+	if s.Oldest != nil {
+		fmt.Fprintf(w, "oldest:             %s\n", s.Oldest.Format(time.RFC3339))
+		fmt.Fprintf(w, "newest:             %s\n", s.Newest.Format(time.RFC3339))
+	}
+
+	cameras := make([]string, 0, len(s.Cameras))
+	for camera := range s.Cameras {
+		cameras = append(cameras, camera)
+	}
+	// the most used first
+	sort.Slice(cameras, func(i, j int) bool {
+		if s.Cameras[cameras[i]] != s.Cameras[cameras[j]] {
+			return s.Cameras[cameras[i]] > s.Cameras[cameras[j]]
+		}
+		return cameras[i] < cameras[j]
+	})
+	if len(cameras) > 0 {
+		fmt.Fprintln(w, "cameras:")
+	}
+	for _, camera := range cameras {
+		fmt.Fprintf(w, "  %-30s %d\n", camera, s.Cameras[camera])
+	}
+}
+
+// e.g. 1.5 MiB
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+// a difference between the source and the generated files
+type problem struct {
+	Album string `json:"album"`
+	File  string `json:"file,omitempty"`
+	// what is wrong
+	Problem string `json:"problem"`
+}
+
+func setupVerify(flags *flag.FlagSet, opts *options) {
+	addConfigFlags(flags, opts)
+	addPathFlags(flags, opts)
+	flags.Var(&opts.Sizes, "size", "the thumbnails of this size must exist (required). You can use this parameter more than once.")
+	flags.IntVar(&opts.CCSize, "cc-size", -1, "the Chromecast file and the thumbnails of this size must exist.")
+	flags.IntVar(&opts.FirstXMeta, "first-x-meta", -1, "if > 0, the file '"+mfg.META_NAME_FIRST_X+"' must exist.")
+	flags.IntVar(&opts.LastXMeta, "last-x-meta", -1, "if > 0, the file '"+mfg.META_NAME_LAST_X+"' must exist.")
+	flags.BoolVar(&opts.GeoJSON, "geojson", false, "the geojson files must exist.")
+	flags.BoolVar(&opts.requireAlt, "require-alt", false, "every image must have an alt text.")
+	addLogFlags(flags, opts)
+	addFormatFlag(flags, opts)
+}
+
+func runVerify(flags *flag.FlagSet, opts *options) {
+	requireFlags(flags, opts.ImagePath != "" && len(opts.Sizes) > 0 && isValidFormat(opts.format))
+
+	err := opts.Validate()
+	mfg.CheckError(err, "Invalid options.")
+	// only the images of the source are needed
+	opts.ForceUpdate = true
+
+	content, err := mfg.ReadFolder(&opts.Options, ".")
+	mfg.CheckError(err, "Can't read the folders.")
+	problems, err := verifyFolder(opts, content, []problem{})
+	mfg.CheckError(err, "Can't verify the gallery.")
+
+	printProblems(os.Stdout, opts.format, problems)
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Compares recursively the folder of the source with its meta files and thumbnails in the output.
+func verifyFolder(opts *options, folder *mfg.FolderContent, problems []problem) ([]problem, error) {
+	add := func(file string, format string, args ...interface{}) {
+		problems = append(problems, problem{Album: folder.FullPath, File: file, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	metaFile := path.Join(folder.FullPath, mfg.META_NAME)
+	meta, err := mfg.ReadMetaJson(opts.Output, metaFile)
+	if os.IsNotExist(err) {
+		add(mfg.META_NAME, "the meta file is missing")
+	} else if err != nil {
+		add(mfg.META_NAME, "invalid meta file: %v", err)
+	} else {
+		listed := make(map[string]bool)
+		for _, img := range meta.Images {
+			listed[img.Filename] = true
+		}
+		existing := make(map[string]bool)
+		for _, imgFile := range folder.Files {
+			existing[imgFile] = true
+			if !listed[imgFile] {
+				add(imgFile, "the image is missing in the meta file")
+			}
+		}
+		for _, img := range meta.Images {
+			if !existing[img.Filename] {
+				add(img.Filename, "the image in the meta file doesn't exist")
+			} else if opts.requireAlt && (img.Alt == nil || *img.Alt == "") {
+				add(img.Filename, "the alt text is missing")
+			}
+		}
+
+		listed = make(map[string]bool)
+		for _, sub := range meta.SubDirs {
+			listed[sub.FolderName] = true
+		}
+		existing = make(map[string]bool)
+		for _, sub := range folder.Folder {
+			existing[sub.Name] = true
+			if !listed[sub.Name] {
+				add(sub.Name, "the album is missing in the meta file")
+			}
+		}
+		for _, sub := range meta.SubDirs {
+			if !existing[sub.FolderName] {
+				add(sub.FolderName, "the album in the meta file doesn't exist")
+			}
+		}
+	}
+
+	for _, optional := range []struct {
+		file       string
+		configured bool
+	}{
+		{mfg.META_NAME_CHROMECAST, opts.CCSize > 0},
+		{mfg.META_NAME_FIRST_X, opts.FirstXMeta > 0},
+		{mfg.META_NAME_LAST_X, opts.LastXMeta > 0},
+		{mfg.META_NAME_GEOJSON, opts.GeoJSON},
+		{mfg.META_NAME_GEOJSON_ALBUMS, opts.GeoJSON && folder.FullPath == "."},
+	} {
+		if !optional.configured {
+			continue
+		}
+		if _, err := fs.Stat(opts.Output, path.Join(folder.FullPath, optional.file)); err != nil {
+			add(optional.file, "the meta file is missing")
+		}
+	}
+
+	missing, err := mfg.MissingThumbnails(&opts.Options, folder)
+	if err != nil {
+		return nil, err
+	}
+	for _, thumbnail := range missing {
+		add(path.Join(mfg.THUMB_DIR, path.Base(thumbnail)), "the thumbnail is missing")
+	}
+
+	for i := range folder.Folder {
+		if problems, err = verifyFolder(opts, &folder.Folder[i], problems); err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
+
+func printProblems(w io.Writer, format string, problems []problem) {
+	if format == "json" {
+		data, err := json.MarshalIndent(problems, "", "  ")
+		mfg.CheckError(err)
+		fmt.Fprintln(w, string(data))
+		return
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s: %s: %s\n", p.Album, p.File, p.Problem)
+	}
+	if len(problems) == 0 {
+		log.Info("The gallery is complete")
+	} else {
+		log.WithField("problems", len(problems)).Warn("The gallery isn't complete")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	log "github.com/sirupsen/logrus"
+)
+
+// these files are written by us and must not trigger a rebuild
+var generatedFiles = map[string]bool{
+	mfg.META_NAME:                true,
+	mfg.META_NAME_CHROMECAST:     true,
+	mfg.META_NAME_FIRST_X:        true,
+	mfg.META_NAME_LAST_X:         true,
+	mfg.META_NAME_GEOJSON:        true,
+	mfg.META_NAME_GEOJSON_ALBUMS: true,
+	mfg.STATE_NAME:               true,
+}
+
+func setupWatch(flags *flag.FlagSet, opts *options) {
+	addConfigFlags(flags, opts)
+	addPathFlags(flags, opts)
+	addThumbnailFlags(flags, opts, "the bounding box of the thumbnails (required). You can use this parameter more than once.")
+	addMetaFlags(flags, opts)
+	addLogFlags(flags, opts)
+	addLockFlags(flags, opts)
+	addMetricsListenFlag(flags, opts)
+	flags.DurationVar(&opts.watchDelay, "watch-delay", 5*time.Second, "the time without any file changes before an album is rebuild.")
+	flags.BoolVar(&opts.watchFiles, "watch-files", true, "rebuilds every changed album. If false, only the admin api starts rebuilds.")
+	flags.StringVar(&opts.adminListen, "admin-listen", "", "serves the admin api on this address or on 'unix:<socket file>'.")
+	addHookFlags(flags, opts)
+}
+
+// Does a full run and keeps running as daemon. Never returns.
+func runWatch(flags *flag.FlagSet, opts *options) {
+	requireFlags(flags, opts.ImagePath != "" && len(opts.Sizes) > 0 && isValidLockMode(opts.lockMode) &&
+		(opts.watchFiles || opts.adminListen != ""))
+
+	err := opts.Validate()
+	mfg.CheckError(err, "Invalid options.")
+	opts.OnThumbnail = logThumbnailError
+
+	if opts.metricsListen != "" {
+		startMetricsServer(opts.metricsListen)
+	}
+
+	lock := lockGallery(opts)
+	defer lock.release()
+	releaseOnSignal(lock)
+
+	message, err := build(opts)
+	mfg.CheckError(err, message)
+	// the first run has already refreshed all meta files
+	opts.ForceUpdate = false
+
+	queue := newJobQueue(opts)
+	if opts.adminListen != "" {
+		startAdminServer(opts.adminListen, queue)
+	}
+	if opts.watchFiles {
+		watch(opts, queue)
+	}
+	select {}
+}
+
+// Watches the image path for changes and queues a rebuild for every changed album after no more events came in for
+// opts.watchDelay. Never returns.
+func watch(opts *options, queue *jobQueue) {
+	watcher, err := fsnotify.NewWatcher()
+	mfg.CheckError(err, "Can't create the file watcher.")
+	defer watcher.Close()
+
+	root := path.Clean(opts.ImagePath)
+	addWatches(watcher, root)
+	log.WithField("path", opts.ImagePath).Info("Watching for changes...")
+
+	dirty := make(map[string]bool)
+	debounce := time.NewTimer(opts.watchDelay)
+	debounce.Stop()
+	for {
+		select {
+		case event := <-watcher.Events:
+			album, ok := affectedAlbum(opts.Output, root, event.Name)
+			if !ok {
+				continue
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create && album == event.Name {
+				// a new folder, its content can only be watched from now on
+				addWatches(watcher, album)
+			}
+			dirty[album] = true
+			debounce.Reset(opts.watchDelay)
+		case err := <-watcher.Errors:
+			log.WithError(err).Error("Watch error")
+		case <-debounce.C:
+			for _, album := range topmostAlbums(dirty) {
+				rel, err := filepath.Rel(root, album)
+				mfg.CheckError(err)
+				if _, err := queue.add(rel); err != nil {
+					log.WithField("album", album).WithError(err).Error("Can't queue rebuild")
+				}
+			}
+			dirty = make(map[string]bool)
+		}
+	}
+}
+
+// adds a watch for the folder and all its sub folders, except the .xxxx folder
+func addWatches(watcher *fsnotify.Watcher, folder string) {
+	filepath.Walk(folder, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.WithField("file", file).WithError(err).Warn("Can't watch")
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if file != folder && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(file); err != nil {
+			log.WithField("album", file).WithError(err).Warn("Can't watch")
+		}
+		return nil
+	})
+}
+
+// Returns the album which has to be rebuild for a changed file below root. This is the folder itself for a new or
+// removed folder, otherwise the folder of the file. Returns false, if the change doesn't matter.
+func affectedAlbum(output fs.FS, root string, file string) (string, bool) {
+	name := path.Base(file)
+	if strings.HasPrefix(name, ".") || generatedFiles[name] {
+		return "", false
+	}
+
+	info, err := os.Stat(file)
+	if err == nil && info.IsDir() {
+		return file, true
+	}
+	if mfg.IsImageFile(name) || name == mfg.CONTENT_INI || name == mfg.CAPTIONS_NAME || mfg.IsGPXFile(name) || mfg.IsSidecarFile(name) {
+		return path.Dir(file), true
+	}
+	if os.IsNotExist(err) && wasSubAlbum(output, root, file) {
+		return file, true
+	}
+	return "", false
+}
+
+// returns true, if the meta.json of the folder of the removed file lists it as sub album
+func wasSubAlbum(output fs.FS, root string, file string) bool {
+	folder, err := filepath.Rel(root, path.Dir(file))
+	if err != nil {
+		return false
+	}
+	meta, err := mfg.ReadMetaJson(output, path.Join(filepath.ToSlash(folder), mfg.META_NAME))
+	if err != nil {
+		return false
+	}
+	for _, subDir := range meta.SubDirs {
+		if subDir.FolderName == path.Base(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the albums without the ones, which are part of another album in the set. An album is always rebuild
+// together with its sub albums.
+func topmostAlbums(albums map[string]bool) []string {
+	var result []string
+	for album := range albums {
+		covered := false
+		for dir := album; dir != path.Dir(dir); {
+			dir = path.Dir(dir)
+			if albums[dir] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result = append(result, album)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
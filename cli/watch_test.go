@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mfg "github.com/ktt-ol/mfGalleryMetaCreatorGo"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_affectedAlbum(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "A"), 0755))
+	for name, data := range map[string]string{
+		mfg.META_NAME: `{"subDirs": [{"foldername": "B"}]}`, "notes.txt": "", "A/a.jpg": "",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(root, name), []byte(data), 0644))
+	}
+
+	tests := []struct {
+		file  string
+		album string
+	}{
+		{"A", "A"},
+		{"A/a.jpg", "A"},
+		{"A/removed.jpg", "A"},
+		{"A/" + mfg.CONTENT_INI, "A"},
+		// the removed album of the last run
+		{"B", "B"},
+		{"notes.txt", ""},
+		{"removed.txt", ""},
+		{mfg.META_NAME, ""},
+		{"A/.hidden", ""},
+	}
+	for _, tt := range tests {
+		album, ok := affectedAlbum(os.DirFS(root), root, filepath.Join(root, tt.file))
+		require.Equal(t, tt.album != "", ok, tt.file)
+		if ok {
+			require.Equal(t, filepath.Join(root, tt.album), album, tt.file)
+		}
+	}
+}
+
+func Test_topmostAlbums(t *testing.T) {
+	require.Equal(t, []string{"/g/a", "/g/c"},
+		topmostAlbums(map[string]bool{"/g/a": true, "/g/a/b/c": true, "/g/c/d": true, "/g/c": true}))
+	require.Equal(t, []string{"/g/a/b", "/g/a/c", "/g/ab"},
+		topmostAlbums(map[string]bool{"/g/a/b": true, "/g/a/c": true, "/g/ab": true}))
+	require.Nil(t, topmostAlbums(map[string]bool{}))
+}
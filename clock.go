@@ -0,0 +1,67 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"strings"
+	"time"
+)
+
+// the prefix of a clock rule for the serial number of the camera body, e.g. "serial 0123456789"
+const CLOCK_SERIAL_PREFIX = "serial "
+
+// Adds a clock rule of the [clock] section: the offset is added to the capture time of the camera. The camera is the
+// model (e.g. "Canon EOS 80D"), the make and the model (e.g. "Canon Canon EOS 80D"), the make (e.g. "Canon") or the
+// body serial number with CLOCK_SERIAL_PREFIX, case-insensitive.
+func (c *FolderConfig) addClockRule(camera string, offset time.Duration) {
+	if c.Clock == nil {
+		c.Clock = make(map[string]time.Duration)
+	}
+	c.Clock[strings.ToLower(strings.TrimSpace(camera))] = offset
+}
+
+// Returns the clock offset of the camera. The most specific rule wins: the serial number, the make and the model, the
+// model, the make.
+func (c *FolderConfig) clockOffset(exif *metaJsonExif) (time.Duration, bool) {
+	var cameras []string
+	if exif.bodySerial != nil {
+		cameras = append(cameras, CLOCK_SERIAL_PREFIX+*exif.bodySerial)
+	}
+	if exif.Make != nil && exif.Model != nil {
+		cameras = append(cameras, *exif.Make+" "+*exif.Model)
+	}
+	if exif.Model != nil {
+		cameras = append(cameras, *exif.Model)
+	}
+	if exif.Make != nil {
+		cameras = append(cameras, *exif.Make)
+	}
+	for _, camera := range cameras {
+		if offset, found := c.Clock[strings.ToLower(camera)]; found {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// Corrects the capture time of the image with the clock rule of its camera and keeps the time of the camera clock as
+// the original time. The image must be read from the source, the previous meta file has no serial numbers.
+func (c *FolderConfig) correctClock(image *MetaJsonImage) {
+	if image.Exif.Time == nil || image.Exif.OriginalTime != nil {
+		return
+	}
+	offset, found := c.clockOffset(&image.Exif)
+	if !found {
+		return
+	}
+	original := *image.Exif.Time
+	corrected := original + int64(offset/time.Millisecond)
+	image.Exif.OriginalTime, image.Exif.Time = &original, &corrected
+}
+
+// Returns the capture time of the camera clock, before a clock rule corrected it. The gpxOffset of the tracks is
+// relative to it.
+func (e *metaJsonExif) cameraTime() *int64 {
+	if e.OriginalTime != nil {
+		return e.OriginalTime
+	}
+	return e.Time
+}
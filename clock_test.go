@@ -0,0 +1,84 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xor-gate/goexif2/exif"
+)
+
+func Test_FolderConfig_correctClock(t *testing.T) {
+	source := fstest.MapFS{
+		"party/content.ini": &fstest.MapFile{Data: []byte(
+			"title=Party\n[clock]\nCanon=1h\nCanon EOS 80D=-2m30s\nserial 0815=10s\nNIKON CORPORATION NIKON D750=1m")},
+	}
+	config, err := ReadIniFile(source, "party/content.ini")
+	require.NoError(t, err)
+
+	str := func(value string) *string {
+		return &value
+	}
+	image := func(make string, model string, serial *string) MetaJsonImage {
+		var time int64 = 1625133600000
+		return MetaJsonImage{Exif: metaJsonExif{Make: str(make), Model: str(model), Time: &time, bodySerial: serial}}
+	}
+	tests := []struct {
+		name  string
+		image MetaJsonImage
+		want  time.Duration
+	}{
+		{"make", image("Canon", "Canon EOS 5D", nil), time.Hour},
+		{"model", image("Canon", "Canon EOS 80D", nil), -150 * time.Second},
+		{"serial", image("Canon", "Canon EOS 80D", str("0815")), 10 * time.Second},
+		{"make and model", image("NIKON CORPORATION", "NIKON D750", nil), time.Minute},
+		{"none", image("SONY", "ILCE-7M3", nil), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.correctClock(&tt.image)
+			require.Equal(t, int64(1625133600000)+tt.want.Milliseconds(), *tt.image.Exif.Time)
+			if tt.want == 0 {
+				require.Nil(t, tt.image.Exif.OriginalTime)
+			} else {
+				require.Equal(t, int64(1625133600000), *tt.image.Exif.OriginalTime)
+			}
+
+			// only once
+			config.correctClock(&tt.image)
+			require.Equal(t, int64(1625133600000)+tt.want.Milliseconds(), *tt.image.Exif.Time)
+		})
+	}
+
+	_, err = ReadIniFile(fstest.MapFS{"content.ini": &fstest.MapFile{Data: []byte("[clock]\nCanon=late")}}, "content.ini")
+	require.Error(t, err)
+}
+
+func Test_bodySerialNumber(t *testing.T) {
+	x, err := exif.Decode(bytes.NewReader(testExif(map[uint16]string{0xA431: "0123456789"})))
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", *exifString(x, BodySerialNumber))
+}
+
+func Test_ReadImageInfo_folderConfig(t *testing.T) {
+	source := fstest.MapFS{
+		"party/content.ini": &fstest.MapFile{Data: []byte("timeZone=Europe/Berlin\n[clock]\nserial 0815=1h")},
+		"party/a.jpg": withSegment(testImage(t, 10, 10), 0xE1,
+			testExif(map[uint16]string{0x9003: "2021:07:01 12:00:00", 0xA431: "0815"})),
+		"b.jpg": withSegment(testImage(t, 10, 10), 0xE1, testExif(map[uint16]string{0x9003: "2021:07:01 12:00:00"})),
+	}
+	info, err := ReadImageInfo(source, "party/a.jpg", time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, "+02:00", *info.Exif.TimeOffset)
+	require.Equal(t, time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), *info.Exif.OriginalTime)
+	require.Equal(t, time.Date(2021, 7, 1, 11, 0, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), *info.Exif.Time)
+
+	// without content.ini
+	info, err = ReadImageInfo(source, "b.jpg", nil)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), *info.Exif.Time)
+	require.Nil(t, info.Exif.TimeOffset)
+	require.Nil(t, info.Exif.OriginalTime)
+}
@@ -4,10 +4,18 @@ const (
 	FILE_REGEXP          = `(?i)\.jpe?g$`
 	THUMB_DIR            = ".thumbs"
 	CONTENT_INI          = "content.ini"
+	CAPTIONS_NAME        = "captions.txt"
 	META_NAME            = "meta.json"
 	META_NAME_CHROMECAST = "meta_cc.jsonp.js"
 	META_NAME_LAST_X     = "meta-last.json"
 	META_NAME_FIRST_X    = "meta-first.json"
-	CC_PREFIX            = "ifsImagesDataCallback("
-	CC_SUFFIX            = ");"
+	META_NAME_GEOJSON    = "meta.geojson"
+	// only in the root
+	META_NAME_GEOJSON_ALBUMS = "meta-albums.geojson"
+	CC_PREFIX                = "ifsImagesDataCallback("
+	CC_SUFFIX                = ");"
+	LOCK_NAME                = ".makeMeta.lock"
+	JOURNAL_NAME             = ".makeMeta.journal"
+	// per album, see albumState
+	STATE_NAME = ".makeMeta.state"
 )
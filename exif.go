@@ -0,0 +1,202 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/xor-gate/goexif2/exif"
+	"github.com/xor-gate/goexif2/tiff"
+)
+
+// the tags of the exif sub-IFD, which goexif2 doesn't know
+const (
+	// the offsets to UTC of the DateTime and the DateTimeOriginal (EXIF 2.31), e.g. "+02:00"
+	OffsetTime         exif.FieldName = "OffsetTime"
+	OffsetTimeOriginal exif.FieldName = "OffsetTimeOriginal"
+	// the serial number of the camera body (EXIF 2.3)
+	BodySerialNumber exif.FieldName = "BodySerialNumber"
+)
+
+var extraExifFields = map[uint16]exif.FieldName{
+	0x9010: OffsetTime,
+	0x9011: OffsetTimeOriginal,
+	0xA431: BodySerialNumber,
+}
+
+// the extended exif fields, which can be written to the meta files (the json names)
+var EXIF_FIELDS = [...]string{"exposureTime", "fNumber", "iso", "focalLength", "focalLength35mm", "lensModel", "flash",
+	"whiteBalance", "exposureProgram", "artist", "copyright"}
+
+// the names of the exif exposure programs
+var EXPOSURE_PROGRAMS = map[int]string{
+	1: "manual",
+	2: "normal",
+	3: "aperture priority",
+	4: "shutter priority",
+	5: "creative",
+	6: "action",
+	7: "portrait",
+	8: "landscape",
+}
+
+func IsValidExifField(field string) bool {
+	for _, name := range EXIF_FIELDS {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	exif.RegisterParsers(extraExifParser{})
+}
+
+// loads the extraExifFields of the exif sub-IFD, they are optional: invalid data is skipped
+type extraExifParser struct{}
+
+func (extraExifParser) Parse(x *exif.Exif) error {
+	pointer, err := x.Get(exif.ExifIFDPointer)
+	if err != nil {
+		return nil
+	}
+	offset, err := pointer.Int64(0)
+	if err != nil {
+		return nil
+	}
+	r := bytes.NewReader(x.Raw)
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil
+	}
+	if dir, _, err := tiff.DecodeDir(r, x.Tiff.Order); err == nil {
+		x.LoadTags(dir, extraExifFields, false)
+	}
+	return nil
+}
+
+// reads the extended fields, missing or invalid tags are skipped
+func readExtendedExif(x *exif.Exif, meta *metaJsonExif) {
+	if num, den, ok := exifRat(x, exif.ExposureTime); ok && num > 0 && den > 0 {
+		exposure := formatExposureTime(num, den)
+		meta.ExposureTime = &exposure
+	}
+	if num, den, ok := exifRat(x, exif.FNumber); ok && den > 0 {
+		fNumber := round(float64(num)/float64(den), 1)
+		meta.FNumber = &fNumber
+	}
+	if iso, ok := exifInt(x, exif.ISOSpeedRatings); ok {
+		meta.ISO = &iso
+	}
+	if num, den, ok := exifRat(x, exif.FocalLength); ok && den > 0 {
+		focalLength := round(float64(num)/float64(den), 1)
+		meta.FocalLength = &focalLength
+	}
+	if focalLength, ok := exifInt(x, exif.FocalLengthIn35mmFilm); ok && focalLength > 0 {
+		meta.FocalLength35mm = &focalLength
+	}
+	meta.LensModel = exifString(x, exif.LensModel)
+	if flash, ok := exifInt(x, exif.Flash); ok {
+		// bit 0: the flash fired
+		fired := flash&1 == 1
+		meta.Flash = &fired
+	}
+	if whiteBalance, ok := exifInt(x, exif.WhiteBalance); ok && (whiteBalance == 0 || whiteBalance == 1) {
+		name := [...]string{"auto", "manual"}[whiteBalance]
+		meta.WhiteBalance = &name
+	}
+	if program, ok := exifInt(x, exif.ExposureProgram); ok {
+		if name, found := EXPOSURE_PROGRAMS[program]; found {
+			meta.ExposureProgram = &name
+		}
+	}
+	meta.Artist = exifString(x, exif.Artist)
+	meta.Copyright = exifString(x, exif.Copyright)
+}
+
+// Returns only the extended fields, which are in the set. The basic fields and the gps position are always kept.
+func (e metaJsonExif) only(fields map[string]bool) metaJsonExif {
+	result := metaJsonExif{Make: e.Make, Model: e.Model, Time: e.Time, TimeOffset: e.TimeOffset,
+		OriginalTime: e.OriginalTime, GPS: e.GPS}
+	if fields["exposureTime"] {
+		result.ExposureTime = e.ExposureTime
+	}
+	if fields["fNumber"] {
+		result.FNumber = e.FNumber
+	}
+	if fields["iso"] {
+		result.ISO = e.ISO
+	}
+	if fields["focalLength"] {
+		result.FocalLength = e.FocalLength
+	}
+	if fields["focalLength35mm"] {
+		result.FocalLength35mm = e.FocalLength35mm
+	}
+	if fields["lensModel"] {
+		result.LensModel = e.LensModel
+	}
+	if fields["flash"] {
+		result.Flash = e.Flash
+	}
+	if fields["whiteBalance"] {
+		result.WhiteBalance = e.WhiteBalance
+	}
+	if fields["exposureProgram"] {
+		result.ExposureProgram = e.ExposureProgram
+	}
+	if fields["artist"] {
+		result.Artist = e.Artist
+	}
+	if fields["copyright"] {
+		result.Copyright = e.Copyright
+	}
+	return result
+}
+
+// e.g. 1/250 for short and 2.5 for long exposures
+func formatExposureTime(num int64, den int64) string {
+	if num >= den {
+		return strconv.FormatFloat(round(float64(num)/float64(den), 1), 'f', -1, 64)
+	}
+	return fmt.Sprintf("1/%d", int64(math.Round(float64(den)/float64(num))))
+}
+
+func round(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
+
+func exifRat(x *exif.Exif, field exif.FieldName) (int64, int64, bool) {
+	tag, err := x.Get(field)
+	if err != nil || tag.Count == 0 {
+		return 0, 0, false
+	}
+	num, den, err := tag.Rat2(0)
+	return num, den, err == nil
+}
+
+func exifInt(x *exif.Exif, field exif.FieldName) (int, bool) {
+	tag, err := x.Get(field)
+	if err != nil || tag.Count == 0 {
+		return 0, false
+	}
+	value, err := tag.Int(0)
+	return value, err == nil
+}
+
+// returns nil for a missing or empty value
+func exifString(x *exif.Exif, field exif.FieldName) *string {
+	tag, err := x.Get(field)
+	if err != nil {
+		return nil
+	}
+	value, err := tag.StringVal()
+	if value = strings.TrimSpace(strings.TrimRight(value, "\x00")); err != nil || value == "" {
+		return nil
+	}
+	return &value
+}
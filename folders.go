@@ -0,0 +1,409 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ini/ini"
+	log "github.com/sirupsen/logrus"
+)
+
+var filePattern = regexp.MustCompile(FILE_REGEXP)
+
+// folder date pattern
+var ymdPattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})_(.*)$`)
+var ymPattern = regexp.MustCompile(`^(\d{4})-(\d{2})_(.*)$`)
+var yPattern = regexp.MustCompile(`^(\d{4})_(.*)$`)
+
+// true, if the file name is an image, which is part of the gallery
+func IsImageFile(name string) bool {
+	return filePattern.MatchString(name)
+}
+
+// Reads recursively all images and the content.ini from the source and, if not opts.ForceUpdate, the metadata of the
+// previous run from the output. folder is the path in the source, "." is the root. The options must be validated.
+func ReadFolder(opts *Options, folder string) (*FolderContent, error) {
+	content := FolderContent{FullPath: folder, Name: opts.folderName(folder)}
+	content.ImageMetadata = make(map[string]MetaJsonImage)
+
+	// the images and the sidecars, which changed after the previous meta file
+	var prevMetaTime time.Time
+	changedSidecars := make(map[string]bool)
+	var changedImages []string
+	// all images are read again
+	changedConfig := false
+	var captions FolderConfig
+	var prevState albumState
+	if !opts.ForceUpdate {
+		err := readPrevImageInfos(opts.Output, content.ImageMetadata, path.Join(folder, META_NAME))
+		if err != nil {
+			return nil, err
+		}
+		if prevState, err = readAlbumState(opts.Output, folder); err != nil {
+			return nil, err
+		}
+		if info, err := fs.Stat(opts.Output, path.Join(folder, META_NAME)); err == nil {
+			prevMetaTime = info.ModTime()
+		}
+	}
+
+	files, err := fs.ReadDir(opts.Source, folder)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		// skip .xxxx folder/files
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
+		var fullPath = content.GetFullPathFile(file.Name())
+		if file.IsDir() {
+			sub, err := ReadFolder(opts, fullPath)
+			if err != nil {
+				return nil, err
+			}
+			content.Folder = append(content.Folder, *sub)
+			continue
+		}
+
+		if file.Name() == CONTENT_INI || file.Name() == CAPTIONS_NAME {
+			content.ConfigFiles = append(content.ConfigFiles, file.Name())
+			// e.g. removed captions or enabled gps
+			if info, err := file.Info(); err != nil || info.ModTime().After(prevMetaTime) {
+				changedConfig = true
+			}
+		}
+
+		if file.Name() == CAPTIONS_NAME {
+			if captions, err = readCaptionsFile(opts.Source, fullPath); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if file.Name() == CONTENT_INI {
+			log.WithField("album", folder).Debug("Content INI file found")
+			if content.Config, err = ReadIniFile(opts.Source, fullPath); err != nil {
+				return nil, err
+			}
+			for _, track := range content.Config.GPX {
+				content.Tracks = append(content.Tracks, content.GetFullPathFile(track))
+			}
+			continue
+		}
+
+		if IsGPXFile(file.Name()) {
+			content.Tracks = append(content.Tracks, fullPath)
+			continue
+		}
+
+		if IsSidecarFile(file.Name()) {
+			if info, err := file.Info(); err != nil || info.ModTime().After(prevMetaTime) {
+				changedSidecars[file.Name()] = true
+			}
+			continue
+		}
+
+		if !IsImageFile(file.Name()) {
+			continue
+		}
+
+		// e.g. replaced by an edited version
+		if info, err := file.Info(); err != nil || info.ModTime().After(prevMetaTime) {
+			changedImages = append(changedImages, file.Name())
+		}
+		content.Files = append(content.Files, file.Name())
+		ImagesScanned.Inc()
+	}
+
+	// the captions of the content.ini win
+	for file, caption := range captions.Captions {
+		if _, found := content.Config.Captions[file]; !found {
+			content.Config.addCaption(file, caption)
+		}
+	}
+	for file, alt := range captions.AltTexts {
+		if _, found := content.Config.AltTexts[file]; !found {
+			content.Config.addCaption(file+ALT_TEXT_SUFFIX, alt)
+		}
+	}
+
+	if newAlbumState(opts, &content).changed(prevState) {
+		changedConfig = true
+	}
+
+	// the metadata of these images is read again
+	if changedConfig {
+		content.ImageMetadata = make(map[string]MetaJsonImage)
+	}
+	for _, image := range changedImages {
+		delete(content.ImageMetadata, image)
+	}
+	for _, image := range content.Files {
+		for _, sidecar := range xmpSidecars(image) {
+			if changedSidecars[sidecar] {
+				delete(content.ImageMetadata, image)
+			}
+		}
+	}
+
+	return &content, nil
+}
+
+// reads recursively all meta data from the source, if needed
+func UpdateImageMetaInfos(opts *Options, folder *FolderContent) error {
+	for i := range folder.Folder {
+		if err := UpdateImageMetaInfos(opts, &folder.Folder[i]); err != nil {
+			return err
+		}
+	}
+	return updateFolderMetaInfos(opts, folder, nil)
+}
+
+// Reads the missing meta data of the images of the folder, from the journal or from the source, and sets the title,
+// the time and the place of the folder. The sub folders must be updated before.
+func updateFolderMetaInfos(opts *Options, folder *FolderContent, journal *journal) error {
+	var track track
+	if len(folder.Files) > 0 && len(folder.Tracks) > 0 {
+		if !folder.Config.GPS {
+			log.WithField("album", folder.FullPath).Warn("The gpx tracks are ignored, gps isn't enabled in the content.ini")
+		}
+		var err error
+		if track, err = readTrack(opts.Source, folder.Tracks); err != nil {
+			return err
+		}
+	}
+
+	var newestTime int64 = math.MinInt64
+	images := make([]MetaJsonImage, len(folder.Files))
+	for i, imgFile := range folder.Files {
+		imgMeta, exists := folder.ImageMetadata[imgFile]
+		if !exists {
+			fullPath := folder.GetFullPathFile(imgFile)
+			if imgMeta, exists = journal.image(fullPath); !exists {
+				var err error
+				imgMeta, err = folder.Config.readImageInfo(opts.Source, imgFile, fullPath, opts.timeZone)
+				if err != nil {
+					return err
+				}
+				// the journal is in the output, too
+				imgMeta.Exif.GPS = folder.Config.exportGPS(imgMeta.Exif.GPS)
+				journal.addImage(fullPath, imgMeta)
+				MetadataReads.Inc()
+			}
+		}
+		// the position of the exif data wins, the tracks may have changed since the previous run
+		if imgMeta.Exif.GPS == nil || imgMeta.Exif.GPS.Source == GPS_SOURCE_GPX {
+			imgMeta.Exif.GPS = track.position(imgMeta.Exif.cameraTime(), &folder.Config)
+		}
+		// the previous meta file may be written with a less strict content.ini
+		imgMeta.Exif.GPS = folder.Config.exportGPS(imgMeta.Exif.GPS)
+		// from the allowed position only
+		imgMeta.Place = opts.places.lookup(imgMeta.Exif.GPS)
+		folder.Config.applyCaptions(&imgMeta)
+		folder.ImageMetadata[imgFile] = imgMeta
+		images[i] = imgMeta
+
+		if imgMeta.Exif.Time != nil && *imgMeta.Exif.Time > newestTime {
+			newestTime = *imgMeta.Exif.Time
+		}
+	}
+
+	title, _, _ := parseTitleAndDateFromFoldername(folder.Name)
+	folder.Title = strings.Replace(title, "_", " ", -1)
+	folder.Time = ownFolderTime(folder.Name, newestTime)
+	folder.Place = dominantPlace(images)
+
+	for i := range folder.Folder {
+		// update the folder time if any sub folder has a newer time
+		if folder.Time == nil || (folder.Folder[i].Time != nil && *folder.Time < *folder.Folder[i].Time) {
+			folder.Time = folder.Folder[i].Time
+		}
+	}
+	return nil
+}
+
+// Returns the time of the folder without its sub folders: the date from the folder name or the time of the newest image.
+// newestTime is math.MinInt64, if no image has a time.
+func ownFolderTime(folderName string, newestTime int64) *int64 {
+	if _, timestamp, ok := parseTitleAndDateFromFoldername(folderName); ok {
+		fTime := timestamp.UnixNano() / 1000 / 1000
+		return &fTime
+	}
+	if newestTime != math.MinInt64 {
+		return &newestTime
+	}
+	return nil
+}
+
+func parseTitleAndDateFromFoldername(filename string) (string, time.Time, bool) {
+	result := ymdPattern.FindStringSubmatch(filename)
+	if len(result) > 0 {
+		year, _ := strconv.Atoi(result[1])
+		month, _ := strconv.Atoi(result[2])
+		day, _ := strconv.Atoi(result[3])
+		return result[4], time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), true
+	}
+
+	result = ymPattern.FindStringSubmatch(filename)
+	if len(result) > 0 {
+		year, _ := strconv.Atoi(result[1])
+		month, _ := strconv.Atoi(result[2])
+		return result[3], time.Date(year, time.Month(month), 0, 0, 0, 0, 0, time.UTC), true
+	}
+
+	result = yPattern.FindStringSubmatch(filename)
+	if len(result) > 0 {
+		year, _ := strconv.Atoi(result[1])
+		return result[2], time.Date(year, 0, 0, 0, 0, 0, 0, time.UTC), true
+	}
+
+	return filename, time.Time{}, false
+}
+
+// creates the entry of a sub folder for the meta.json of its parent folder
+func NewSubDirEntry(folder *FolderContent) MetaJsonSubDir {
+	sub := MetaJsonSubDir{
+		FolderName: folder.Name,
+		Title:      folder.GetFolderTitle(),
+		Time:       folder.Time,
+		ImageCount: sumFolderImageCount(folder),
+		Place:      folder.Place,
+	}
+	if len(folder.Config.Cover) > 0 {
+		sub.Cover = &folder.Config.Cover
+	} else if len(folder.Files) > 0 {
+		sub.Cover = &folder.Files[0]
+	}
+	return sub
+}
+
+// calculates the amount of photos of this folder inclusive all images in sub folders
+func sumFolderImageCount(folder *FolderContent) int {
+	sum := len(folder.Files)
+	for _, sub := range folder.Folder {
+		sum += sumFolderImageCount(&sub)
+	}
+	return sum
+}
+
+func ReadIniFile(fsys fs.FS, iniFile string) (FolderConfig, error) {
+	config := FolderConfig{}
+	data, err := fs.ReadFile(fsys, iniFile)
+	if err != nil {
+		return config, err
+	}
+	cfg, err := ini.Load(data)
+	if err != nil {
+		return config, fmt.Errorf("error reading ini file %s: %v", iniFile, err)
+	}
+	section, err := cfg.GetSection("")
+	if err != nil {
+		return config, fmt.Errorf("error reading section of %s: %v", iniFile, err)
+	}
+
+	title, err := section.GetKey("title")
+	if err == nil {
+		config.Title = title.Value()
+	}
+	description, err := section.GetKey("description")
+	if err == nil {
+		config.Description = description.Value()
+	}
+	cover, err := section.GetKey("cover")
+	if err == nil {
+		config.Cover = cover.Value()
+	}
+	if gps, err := section.GetKey("gps"); err == nil {
+		if config.GPS, err = gps.Bool(); err != nil {
+			return config, fmt.Errorf("invalid gps in %s: %v", iniFile, err)
+		}
+	}
+	if gpx, err := section.GetKey("gpx"); err == nil {
+		config.GPX = gpx.Strings(",")
+	}
+	if offset, err := section.GetKey("gpxOffset"); err == nil {
+		if config.GPXOffset, err = offset.Duration(); err != nil {
+			return config, fmt.Errorf("invalid gpxOffset in %s: %v", iniFile, err)
+		}
+	}
+	if maxGap, err := section.GetKey("gpxMaxGap"); err == nil {
+		if config.GPXMaxGap, err = maxGap.Duration(); err != nil || config.GPXMaxGap <= 0 {
+			return config, fmt.Errorf("invalid gpxMaxGap in %s, must be a positive duration", iniFile)
+		}
+	}
+	if timeZone, err := section.GetKey("timeZone"); err == nil {
+		if config.TimeZone, err = time.LoadLocation(timeZone.Value()); err != nil {
+			return config, fmt.Errorf("invalid timeZone in %s: %v", iniFile, err)
+		}
+	}
+	if clock, err := cfg.GetSection("clock"); err == nil {
+		for _, key := range clock.Keys() {
+			offset, err := key.Duration()
+			if err != nil {
+				return config, fmt.Errorf("invalid clock offset of %s in %s: %v", key.Name(), iniFile, err)
+			}
+			config.addClockRule(key.Name(), offset)
+		}
+	}
+	if images, err := cfg.GetSection("images"); err == nil {
+		for _, key := range images.Keys() {
+			config.addCaption(key.Name(), key.Value())
+		}
+	}
+	config.GPSPrecision = -1
+	if precision, err := section.GetKey("gpsPrecision"); err == nil {
+		config.GPSPrecision, err = precision.Int()
+		if err != nil || config.GPSPrecision < 0 || config.GPSPrecision > MAX_GPS_PRECISION {
+			return config, fmt.Errorf("invalid gpsPrecision in %s, must be 0 to %d", iniFile, MAX_GPS_PRECISION)
+		}
+	}
+
+	return config, nil
+}
+
+// reads the images of the previous meta file, if it exists
+func readPrevImageInfos(fsys fs.FS, metaMap map[string]MetaJsonImage, jsonFile string) error {
+	if found, err := exists(fsys, jsonFile); !found {
+		return err
+	}
+	log.WithField("album", path.Dir(jsonFile)).Debug("Previous generated meta file found")
+	jsonContent, err := ReadMetaJson(fsys, jsonFile)
+	if err != nil {
+		return err
+	}
+	for _, imgInfo := range jsonContent.Images {
+		metaMap[imgInfo.Filename] = imgInfo
+	}
+	return nil
+}
+
+func ReadMetaJson(fsys fs.FS, jsonFile string) (MetaJson, error) {
+	var jsonContent MetaJson
+	bytes, err := fs.ReadFile(fsys, jsonFile)
+	if err != nil {
+		return jsonContent, err
+	}
+
+	if err = json.Unmarshal(bytes, &jsonContent); err != nil {
+		return jsonContent, fmt.Errorf("invalid json in file %s: %v", jsonFile, err)
+	}
+	return jsonContent, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
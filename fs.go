@@ -0,0 +1,71 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// All paths in the file systems are slash separated and relative to the root, "." is the root itself (see io/fs).
+
+// MetaWriter is the target of all generated meta files.
+type MetaWriter interface {
+	// Creates or replaces the file, the parent folders are created if needed.
+	WriteFile(name string, data []byte) error
+}
+
+// OutputFS is the target of all generated files: the meta files and the thumbnails. It must be readable, because
+// the meta files of the previous run and the existing thumbnails are read from it.
+type OutputFS interface {
+	fs.FS
+	MetaWriter
+	// Creates or truncates the file for writing, the parent folders are created if needed.
+	Create(name string) (io.WriteCloser, error)
+	Remove(name string) error
+}
+
+// DirFS is a folder on the local disk. It can be used as source (fs.FS) and as OutputFS.
+type DirFS struct {
+	fs.FS
+	root string
+}
+
+func NewDirFS(root string) DirFS {
+	return DirFS{FS: os.DirFS(root), root: root}
+}
+
+// Returns the path on the local disk.
+func (d DirFS) LocalPath(name string) string {
+	return filepath.Join(d.root, filepath.FromSlash(name))
+}
+
+func (d DirFS) WriteFile(name string, data []byte) error {
+	file := d.LocalPath(name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+func (d DirFS) Create(name string) (io.WriteCloser, error) {
+	file := d.LocalPath(name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(file)
+}
+
+func (d DirFS) Remove(name string) error {
+	return os.Remove(d.LocalPath(name))
+}
+
+// true, if the file exists in the file system
+func exists(fsys fs.FS, name string) (bool, error) {
+	_, err := fs.Stat(fsys, name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
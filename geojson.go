@@ -0,0 +1,165 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type geoJsonFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJsonFeature `json:"features"`
+}
+
+type geoJsonFeature struct {
+	Type string `json:"type"`
+	// only for albums: [west, south, east, north]
+	BBox       []float64       `json:"bbox,omitempty"`
+	Geometry   geoJsonGeometry `json:"geometry"`
+	Properties interface{}     `json:"properties"`
+}
+
+type geoJsonGeometry struct {
+	Type string `json:"type"`
+	// longitude, latitude and the optional altitude
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// the properties of an image in the meta.geojson of its album
+type geoJsonImage struct {
+	Filename string `json:"filename"`
+	// relative to the album
+	Thumbnail string `json:"thumbnail"`
+	Time      *int64 `json:"time"`
+	// the title of the image, the title of the album for an image without own title
+	Title string `json:"title"`
+}
+
+// the properties of an album in the meta-albums.geojson of the root
+type geoJsonAlbum struct {
+	// relative to the root, "" is the root album
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	Time  *int64 `json:"time"`
+	// the geotagged images of the album without its sub albums
+	ImageCount int `json:"imageCount"`
+}
+
+func newFeatureCollection() geoJsonFeatureCollection {
+	return geoJsonFeatureCollection{Type: "FeatureCollection", Features: []geoJsonFeature{}}
+}
+
+func newPoint(latitude float64, longitude float64) geoJsonGeometry {
+	return geoJsonGeometry{Type: "Point", Coordinates: []float64{longitude, latitude}}
+}
+
+// Writes the meta.geojson of the folder with a point for every image with a gps position. The images must be
+// exported, so only the allowed positions are written.
+func writeGeoJson(out MetaWriter, opts *Options, folderPath string, meta MetaJson) error {
+	thumbnailSize := opts.Sizes[0]
+	for _, size := range opts.Sizes {
+		if size < thumbnailSize {
+			thumbnailSize = size
+		}
+	}
+
+	collection := newFeatureCollection()
+	for _, image := range meta.Images {
+		gps := image.Exif.GPS
+		if gps == nil {
+			continue
+		}
+		point := newPoint(gps.Latitude, gps.Longitude)
+		if gps.Altitude != nil {
+			point.Coordinates = append(point.Coordinates, *gps.Altitude)
+		}
+		title := meta.Meta.Title
+		if image.Title != nil {
+			title = *image.Title
+		}
+		collection.Features = append(collection.Features, geoJsonFeature{
+			Type:     "Feature",
+			Geometry: point,
+			Properties: geoJsonImage{
+				Filename:  image.Filename,
+				Thumbnail: fmt.Sprintf("%s/%d-%s", THUMB_DIR, thumbnailSize, image.Filename),
+				Time:      image.Exif.Time,
+				Title:     title,
+			},
+		})
+	}
+	return writeAsJson(out, collection, path.Join(folderPath, META_NAME_GEOJSON))
+}
+
+// Writes the meta-albums.geojson of the root with a feature for every album with geotagged images: a point at the
+// center of the images and the bounding box. The albums are read from the meta files in the output, so the meta
+// files of the changed albums must be written before. Does nothing, if opts.GeoJSON isn't set.
+func (b *AlbumBuilder) WriteGeoJsonIndex() error {
+	if !b.Options.GeoJSON {
+		return nil
+	}
+	log.Debug("Writing the geojson file of the albums")
+	collection := newFeatureCollection()
+	err := addGeoJsonAlbums(b.Options.Output, ".", &collection)
+	if err == nil {
+		err = writeAsJson(b.Out, collection, META_NAME_GEOJSON_ALBUMS)
+	}
+	if err != nil {
+		CountFailure(PHASE_META_FILES)
+	}
+	return err
+}
+
+// adds the album and recursively its sub albums, an album without meta file is skipped
+func addGeoJsonAlbums(fsys fs.FS, folderPath string, collection *geoJsonFeatureCollection) error {
+	meta, err := ReadMetaJson(fsys, path.Join(folderPath, META_NAME))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	west, south, east, north := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	var latitudes, longitudes float64
+	count := 0
+	for _, image := range meta.Images {
+		gps := image.Exif.GPS
+		if gps == nil {
+			continue
+		}
+		west, east = math.Min(west, gps.Longitude), math.Max(east, gps.Longitude)
+		south, north = math.Min(south, gps.Latitude), math.Max(north, gps.Latitude)
+		latitudes += gps.Latitude
+		longitudes += gps.Longitude
+		count++
+	}
+	if count > 0 {
+		albumPath := folderPath
+		if albumPath == "." {
+			albumPath = ""
+		}
+		collection.Features = append(collection.Features, geoJsonFeature{
+			Type:     "Feature",
+			BBox:     []float64{west, south, east, north},
+			Geometry: newPoint(latitudes/float64(count), longitudes/float64(count)),
+			Properties: geoJsonAlbum{
+				Path:       albumPath,
+				Title:      meta.Meta.Title,
+				Time:       meta.Meta.Time,
+				ImageCount: count,
+			},
+		})
+	}
+
+	for _, sub := range meta.SubDirs {
+		if err := addGeoJsonAlbums(fsys, path.Join(folderPath, sub.FolderName), collection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
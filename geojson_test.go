@@ -0,0 +1,60 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_writeGeoJson(t *testing.T) {
+	title := "Summit"
+	gps := &metaJsonGPS{Latitude: 47.4, Longitude: 10.9}
+	meta := MetaJson{Meta: MetaJsonMeta{Title: "Hike"}, Images: []MetaJsonImage{
+		{Filename: "a.jpg", Title: &title, Exif: metaJsonExif{GPS: gps}},
+		{Filename: "b.jpg", Exif: metaJsonExif{GPS: gps}},
+		{Filename: "nogps.jpg", Title: &title},
+	}}
+	out := newMemOutput()
+	require.NoError(t, writeGeoJson(out, &Options{Sizes: IntList{200, 20}}, "Hike", meta))
+
+	var collection geoJsonFeatureCollection
+	require.NoError(t, json.Unmarshal(out.MapFS["Hike/"+META_NAME_GEOJSON].Data, &collection))
+	require.Len(t, collection.Features, 2)
+	require.Equal(t, map[string]interface{}{"filename": "a.jpg", "thumbnail": ".thumbs/20-a.jpg", "time": nil,
+		"title": "Summit"}, collection.Features[0].Properties)
+	// the title of the album
+	require.Equal(t, "Hike", collection.Features[1].Properties.(map[string]interface{})["title"])
+}
+
+func Test_AlbumBuilder_WriteGeoJsonIndex(t *testing.T) {
+	meta := func(title string, subDirs []string, positions ...[2]float64) *fstest.MapFile {
+		content := MetaJson{Meta: MetaJsonMeta{Title: title}, Images: []MetaJsonImage{{Filename: "nogps.jpg"}}}
+		for _, position := range positions {
+			content.Images = append(content.Images,
+				MetaJsonImage{Exif: metaJsonExif{GPS: &metaJsonGPS{Latitude: position[0], Longitude: position[1]}}})
+		}
+		for _, sub := range subDirs {
+			content.SubDirs = append(content.SubDirs, MetaJsonSubDir{FolderName: sub})
+		}
+		data, err := json.Marshal(content)
+		require.NoError(t, err)
+		return &fstest.MapFile{Data: data}
+	}
+	out := newMemOutput()
+	out.MapFS[META_NAME] = meta("Root", []string{"Trip", "Home", "Removed"})
+	out.MapFS["Trip/"+META_NAME] = meta("Trip", nil, [2]float64{53, 8}, [2]float64{54, 10})
+	out.MapFS["Home/"+META_NAME] = meta("Home", nil)
+	builder := AlbumBuilder{Options: &Options{Output: out, GeoJSON: true}, Out: out}
+	require.NoError(t, builder.WriteGeoJsonIndex())
+
+	var collection geoJsonFeatureCollection
+	require.NoError(t, json.Unmarshal(out.MapFS[META_NAME_GEOJSON_ALBUMS].Data, &collection))
+	require.Len(t, collection.Features, 1)
+	trip := collection.Features[0]
+	require.Equal(t, []float64{9, 53.5}, trip.Geometry.Coordinates)
+	require.Equal(t, []float64{8, 53, 10, 54}, trip.BBox)
+	require.Equal(t, map[string]interface{}{"path": "Trip", "title": "Trip", "time": nil, "imageCount": float64(2)},
+		trip.Properties)
+}
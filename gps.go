@@ -0,0 +1,49 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"math"
+
+	"github.com/xor-gate/goexif2/exif"
+)
+
+// the maximum number of decimal places of the coordinates in content.ini, more is below the accuracy of a gps
+const MAX_GPS_PRECISION = 8
+
+// reads the position, a missing or invalid position is skipped
+func readGPS(x *exif.Exif, meta *metaJsonExif) {
+	lat, lon, err := x.LatLong()
+	if err != nil || math.IsNaN(lat) || math.IsNaN(lon) || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return
+	}
+	gps := metaJsonGPS{Latitude: lat, Longitude: lon}
+	if num, den, ok := exifRat(x, exif.GPSAltitude); ok && den > 0 {
+		altitude := round(float64(num)/float64(den), 1)
+		// 1: below sea level
+		if ref, ok := exifInt(x, exif.GPSAltitudeRef); ok && ref == 1 {
+			altitude = -altitude
+		}
+		gps.Altitude = &altitude
+	}
+	if num, den, ok := exifRat(x, exif.GPSImgDirection); ok && den > 0 {
+		direction := round(float64(num)/float64(den), 1)
+		gps.Direction = &direction
+	}
+	meta.GPS = &gps
+}
+
+// Returns the position, which may be written for the images of the folder: nil, if gps isn't enabled in the
+// content.ini, otherwise the position with the configured precision.
+func (c *FolderConfig) exportGPS(gps *metaJsonGPS) *metaJsonGPS {
+	if gps == nil || !c.GPS {
+		return nil
+	}
+	result := *gps
+	if c.GPSPrecision >= 0 {
+		result.Latitude = round(result.Latitude, c.GPSPrecision)
+		result.Longitude = round(result.Longitude, c.GPSPrecision)
+		// the altitude and the direction would narrow down a coarse position again
+		result.Direction = nil
+		result.Altitude = nil
+	}
+	return &result
+}
@@ -0,0 +1,37 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FolderConfig_exportGPS(t *testing.T) {
+	altitude := 12.5
+	gps := &metaJsonGPS{Latitude: 53.14352, Longitude: 8.21467, Altitude: &altitude}
+
+	require.Nil(t, (&FolderConfig{GPSPrecision: -1}).exportGPS(gps))
+	require.Equal(t, gps, (&FolderConfig{GPS: true, GPSPrecision: -1}).exportGPS(gps))
+	require.Equal(t, &metaJsonGPS{Latitude: 53.14, Longitude: 8.21}, (&FolderConfig{GPS: true, GPSPrecision: 2}).exportGPS(gps))
+	require.Equal(t, 53.14352, gps.Latitude)
+}
+
+func Test_ReadIniFile_gps(t *testing.T) {
+	source := fstest.MapFS{
+		"none/content.ini":    &fstest.MapFile{Data: []byte("title=None")},
+		"coarse/content.ini":  &fstest.MapFile{Data: []byte("gps=true\ngpsPrecision=2")},
+		"invalid/content.ini": &fstest.MapFile{Data: []byte("gps=true\ngpsPrecision=-2")},
+	}
+	config, err := ReadIniFile(source, "none/content.ini")
+	require.NoError(t, err)
+	require.False(t, config.GPS)
+
+	config, err = ReadIniFile(source, "coarse/content.ini")
+	require.NoError(t, err)
+	require.True(t, config.GPS)
+	require.Equal(t, 2, config.GPSPrecision)
+
+	_, err = ReadIniFile(source, "invalid/content.ini")
+	require.Error(t, err)
+}
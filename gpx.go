@@ -0,0 +1,131 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	// the default of the maximum time between two track points or between an image and the end of the track
+	GPX_MAX_GAP = 5 * time.Minute
+	// the source of a gps position, which is interpolated from a gpx track
+	GPS_SOURCE_GPX = "gpx"
+)
+
+var gpxPattern = regexp.MustCompile(`(?i)\.gpx$`)
+
+// only the track points of a gpx file
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []trackPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type trackPoint struct {
+	Latitude  float64   `xml:"lat,attr"`
+	Longitude float64   `xml:"lon,attr"`
+	Elevation *float64  `xml:"ele"`
+	Time      time.Time `xml:"time"`
+}
+
+// the points of all tracks of a folder, sorted by time
+type track []trackPoint
+
+// true, if the file name is a gpx track
+func IsGPXFile(name string) bool {
+	return gpxPattern.MatchString(name)
+}
+
+// Reads the track points with a time of the gpx files (paths in the source).
+func readTrack(source fs.FS, files []string) (track, error) {
+	var points track
+	for _, file := range files {
+		data, err := fs.ReadFile(source, file)
+		if err != nil {
+			return nil, err
+		}
+		var gpx gpxFile
+		if err := xml.Unmarshal(data, &gpx); err != nil {
+			return nil, fmt.Errorf("invalid gpx file %s: %v", file, err)
+		}
+		for _, trk := range gpx.Tracks {
+			for _, segment := range trk.Segments {
+				for _, point := range segment.Points {
+					if !point.Time.IsZero() {
+						points = append(points, point)
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	return points, nil
+}
+
+// Returns the position at the capture time (unix ms) of the camera clock, see metaJsonExif.cameraTime: the capture
+// time is corrected by the offset of the folder and the position is interpolated between the track points before and
+// after it. nil, if the image has no time, or the points are farther apart than the maximum gap of the folder. Before
+// the first or after the last point, the position of that point is used within the maximum gap.
+func (t track) position(captureTime *int64, config *FolderConfig) *metaJsonGPS {
+	if len(t) == 0 || captureTime == nil {
+		return nil
+	}
+	maxGap := config.GPXMaxGap
+	if maxGap <= 0 {
+		maxGap = GPX_MAX_GAP
+	}
+	at := time.Unix(0, *captureTime*int64(time.Millisecond)).Add(config.GPXOffset)
+
+	// the first point after the time
+	next := sort.Search(len(t), func(i int) bool {
+		return t[i].Time.After(at)
+	})
+	switch {
+	case next == 0:
+		if t[0].Time.Sub(at) <= maxGap {
+			return t[0].gps()
+		}
+	case next == len(t):
+		if at.Sub(t[next-1].Time) <= maxGap {
+			return t[next-1].gps()
+		}
+	default:
+		before, after := t[next-1], t[next]
+		gap := after.Time.Sub(before.Time)
+		if gap > maxGap {
+			return nil
+		}
+		ratio := 0.0
+		if gap > 0 {
+			ratio = float64(at.Sub(before.Time)) / float64(gap)
+		}
+		gps := &metaJsonGPS{
+			Latitude:  before.Latitude + (after.Latitude-before.Latitude)*ratio,
+			Longitude: before.Longitude + (after.Longitude-before.Longitude)*ratio,
+			Source:    GPS_SOURCE_GPX,
+		}
+		if before.Elevation != nil && after.Elevation != nil {
+			altitude := round(*before.Elevation+(*after.Elevation-*before.Elevation)*ratio, 1)
+			gps.Altitude = &altitude
+		}
+		return gps
+	}
+	return nil
+}
+
+func (p trackPoint) gps() *metaJsonGPS {
+	gps := &metaJsonGPS{Latitude: p.Latitude, Longitude: p.Longitude, Source: GPS_SOURCE_GPX}
+	if p.Elevation != nil {
+		altitude := round(*p.Elevation, 1)
+		gps.Altitude = &altitude
+	}
+	return gps
+}
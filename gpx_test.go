@@ -0,0 +1,75 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><trkseg>
+    <trkpt lat="53.0" lon="8.0"><ele>10</ele><time>2021-06-01T10:00:00Z</time></trkpt>
+    <trkpt lat="53.2" lon="8.4"><ele>20</ele><time>2021-06-01T10:02:00Z</time></trkpt>
+    <trkpt lat="54.0" lon="9.0"><time>2021-06-01T11:00:00Z</time></trkpt>
+    <trkpt lat="1.0" lon="1.0"></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+func Test_track_position(t *testing.T) {
+	source := fstest.MapFS{"hike.gpx": &fstest.MapFile{Data: []byte(testGPX)}}
+	track, err := readTrack(source, []string{"hike.gpx"})
+	require.NoError(t, err)
+	require.Len(t, track, 3)
+
+	at := func(value string) *int64 {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		ms := parsed.UnixNano() / int64(time.Millisecond)
+		return &ms
+	}
+	config := &FolderConfig{}
+	altitude := 15.0
+	require.Equal(t, &metaJsonGPS{Latitude: 53.1, Longitude: 8.2, Altitude: &altitude, Source: GPS_SOURCE_GPX},
+		track.position(at("2021-06-01T10:01:00Z"), config))
+
+	// the camera clock is 2 hours ahead
+	config.GPXOffset = -2 * time.Hour
+	require.Equal(t, 53.1, track.position(at("2021-06-01T12:01:00Z"), config).Latitude)
+	config.GPXOffset = 0
+
+	// the end of the track within the gap, the gap between the points is too large
+	require.Equal(t, 53.0, track.position(at("2021-06-01T09:57:00Z"), config).Latitude)
+	require.Nil(t, track.position(at("2021-06-01T09:50:00Z"), config))
+	require.Nil(t, track.position(at("2021-06-01T10:30:00Z"), config))
+	config.GPXMaxGap = time.Hour
+	require.NotNil(t, track.position(at("2021-06-01T10:30:00Z"), config))
+	require.Nil(t, track.position(nil, config))
+
+	_, err = readTrack(fstest.MapFS{"broken.gpx": &fstest.MapFile{Data: []byte("<gpx>")}}, []string{"broken.gpx"})
+	require.Error(t, err)
+}
+
+func Test_Generate_gpxOffsetWithClock(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		"hike/content.ini": &fstest.MapFile{Data: []byte("gps=true\ngpxOffset=-2h\n[clock]\nserial 0815=-1h")},
+		"hike/hike.gpx":    &fstest.MapFile{Data: []byte(testGPX)},
+		// the camera clock is 2 hours ahead of the track
+		"hike/a.jpg": withSegment(testImage(t, 10, 10), 0xE1,
+			testExif(map[uint16]string{0x9003: "2021:06:01 12:01:00", 0xA431: "0815"})),
+	})
+	require.NoError(t, gallery.generate())
+
+	images := gallery.images("hike")
+	require.Len(t, images, 1)
+	require.Equal(t, time.Date(2021, 6, 1, 11, 1, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), *images[0].Exif.Time)
+	// the offset isn't added to the corrected time
+	require.NotNil(t, images[0].Exif.GPS)
+	require.Equal(t, 53.1, images[0].Exif.GPS.Latitude)
+
+	// again from the meta file
+	require.NoError(t, gallery.generate())
+	require.Equal(t, 53.1, gallery.images("hike")[0].Exif.GPS.Latitude)
+}
@@ -0,0 +1,212 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/xor-gate/goexif2/exif"
+	"github.com/xor-gate/goexif2/tiff"
+)
+
+// Reads the size and the exif data of a single image like the generator does: with the time zone and the clock rules
+// of the content.ini in its folder. The time zone is the default for the images without offset, see -time-zone.
+func ReadImageInfo(source fs.FS, input string, timeZone *time.Location) (MetaJsonImage, error) {
+	var config FolderConfig
+	iniFile := path.Join(path.Dir(input), CONTENT_INI)
+	if found, _ := exists(source, iniFile); found {
+		var err error
+		if config, err = ReadIniFile(source, iniFile); err != nil {
+			return MetaJsonImage{}, err
+		}
+	}
+	return config.readImageInfo(source, path.Base(input), input, timeZone)
+}
+
+// Returns the time zone of the images without offset: the time zone of the content.ini or the default time zone, nil
+// is UTC.
+func (c *FolderConfig) timeZone(defaultZone *time.Location) *time.Location {
+	if c.TimeZone != nil {
+		return c.TimeZone
+	}
+	return defaultZone
+}
+
+// Reads a new image of the folder. The time zone of the content.ini wins over the default time zone, the clock rules
+// correct the time afterwards.
+func (c *FolderConfig) readImageInfo(source fs.FS, filename, input string, defaultZone *time.Location) (MetaJsonImage, error) {
+	imageMeta, err := readImageInfo(source, filename, input, c.timeZone(defaultZone))
+	if err != nil {
+		return imageMeta, err
+	}
+	c.correctClock(&imageMeta)
+	return imageMeta, nil
+}
+
+// Reads the image, the time zone is used for an exif time without offset, see getExifTime.
+func readImageInfo(source fs.FS, filename, input string, timeZone *time.Location) (MetaJsonImage, error) {
+	log.WithField("file", input).Debug("Read image meta info")
+
+	imageConfig, err := readImageConfig(source, input)
+	if err != nil {
+		return MetaJsonImage{}, fmt.Errorf("can't read image %s: %v", input, err)
+	}
+	imageMeta := MetaJsonImage{Filename: filename, Width: imageConfig.Width, Height: imageConfig.Height}
+
+	if captions, err := readCaptions(source, input); err != nil {
+		log.WithField("file", input).WithError(err).Warn("Can't read the xmp and iptc data")
+	} else {
+		captions.apply(&imageMeta)
+	}
+
+	x, err := readExif(source, input)
+	if err != nil {
+		log.WithField("file", input).WithError(err).Warn("Can't read exif")
+		return imageMeta, nil
+	}
+
+	if camModel, err := x.Get(exif.Model); err == nil {
+		if model, err := camModel.StringVal(); err == nil {
+			model = strings.TrimSpace(model)
+			imageMeta.Exif.Model = &model
+		}
+	}
+
+	if camMaker, err := x.Get(exif.Make); err == nil {
+		if maker, err := camMaker.StringVal(); err == nil {
+			maker = strings.TrimSpace(maker)
+			imageMeta.Exif.Make = &maker
+		}
+	}
+
+	if datetime, known, err := getExifTime(x, timeZone); err == nil {
+		timeInMS := datetime.UnixNano() / 1000 / 1000
+		imageMeta.Exif.Time = &timeInMS
+		if known {
+			imageMeta.Exif.TimeOffset = timeOffset(datetime)
+		}
+	}
+
+	imageMeta.Exif.bodySerial = exifString(x, BodySerialNumber)
+	readExtendedExif(x, &imageMeta.Exif)
+	readGPS(x, &imageMeta.Exif)
+
+	imageMeta.Rotate = getExifRotation(x)
+	if imageMeta.Rotate == ROTATE_90 || imageMeta.Rotate == ROTATE_270 {
+		imageMeta.Width, imageMeta.Height = imageMeta.Height, imageMeta.Width
+	}
+
+	return imageMeta, nil
+}
+
+func readImageConfig(source fs.FS, input string) (image.Config, error) {
+	f, err := source.Open(input)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+
+	imageConfig, _, err := image.DecodeConfig(f)
+	return imageConfig, err
+}
+
+// Reads the exif data of the image. Only critical errors are returned.
+func readExif(source fs.FS, input string) (*exif.Exif, error) {
+	f, err := source.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil && exif.IsCriticalError(err) {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Returns all exif tags of the image with their formatted values, e.g. for the inspect command.
+func ReadExifTags(source fs.FS, input string) (map[string]string, error) {
+	x, err := readExif(source, input)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	err = x.Walk(exifTagWalker(func(name exif.FieldName, tag *tiff.Tag) error {
+		tags[string(name)] = tag.String()
+		return nil
+	}))
+	return tags, err
+}
+
+type exifTagWalker func(name exif.FieldName, tag *tiff.Tag) error
+
+func (w exifTagWalker) Walk(name exif.FieldName, tag *tiff.Tag) error {
+	return w(name, tag)
+}
+
+// Reads the rotation of the image from the exif data, no rotation if the data can't be read.
+func ReadRotation(source fs.FS, input string) RotationAction {
+	x, err := readExif(source, input)
+	if err != nil {
+		return NO_ROTATION
+	}
+	return getExifRotation(x)
+}
+
+// returns the rotation which is needed to show the image upright
+func getExifRotation(x *exif.Exif) RotationAction {
+	if orientation, err := x.Get(exif.Orientation); err == nil {
+		if orientationVal, err := orientation.Int(0); err == nil {
+			// http://jpegclub.org/exif_orientation.html
+			if orientationVal == 3 || orientationVal == 4 {
+				return ROTATE_180
+			} else if orientationVal == 5 || orientationVal == 6 {
+				return ROTATE_270
+			} else if orientationVal == 7 || orientationVal == 8 {
+				return ROTATE_90
+			}
+		}
+	}
+	return NO_ROTATION
+}
+
+// Returns the capture time of the exif (DateTimeOriginal, else DateTime) with the sub-seconds. The zone is the
+// offset of the exif, else the time zone of the camera maker note, else the default zone. known is false, if there is
+// no zone: the time is read as UTC then.
+func getExifTime(x *exif.Exif, defaultZone *time.Location) (datetime time.Time, known bool, err error) {
+	// the offset and the sub-seconds belong to their time
+	offsetField, subSecondsField := OffsetTimeOriginal, exif.SubSecTimeOriginal
+	tag, err := x.Get(exif.DateTimeOriginal)
+	if err != nil {
+		offsetField, subSecondsField = OffsetTime, exif.SubSecTime
+		if tag, err = x.Get(exif.DateTime); err != nil {
+			return datetime, false, err
+		}
+	}
+	if tag.Format() != tiff.StringVal {
+		return datetime, false, errors.New("DateTime[Original] not in string format")
+	}
+
+	zone := exifZone(x, offsetField)
+	if zone == nil {
+		zone, _ = x.TimeZone()
+	}
+	if zone == nil {
+		zone = defaultZone
+	}
+	known = zone != nil
+	if !known {
+		zone = time.UTC
+	}
+	dateStr := strings.TrimRight(string(tag.Val), "\x00")
+	if datetime, err = time.ParseInLocation(EXIF_TIME_LAYOUT, dateStr, zone); err != nil {
+		return datetime, false, err
+	}
+	return datetime.Add(exifSubSeconds(x, subSecondsField)), known, nil
+}
@@ -0,0 +1,135 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// the header of the APP13 segment of a jpeg with the photoshop resources
+	PHOTOSHOP_HEADER = "Photoshop 3.0\x00"
+	// the photoshop resource with the iptc data
+	PHOTOSHOP_IPTC = 0x0404
+	// the coded character set of record 1 for utf-8
+	IPTC_UTF8 = "\x1b%G"
+)
+
+const (
+	IPTC_TAG_MARKER = 0x1C
+	// the length of the dataset is in the next bytes
+	IPTC_EXTENDED_LENGTH = 0x8000
+
+	// the envelope record with the coded character set
+	IPTC_RECORD_ENVELOPE = 1
+	IPTC_CHARSET         = 90
+
+	// the application record with the datasets, which are read
+	IPTC_RECORD_APPLICATION = 2
+	IPTC_OBJECT_NAME        = 5
+	IPTC_KEYWORDS           = 25
+	IPTC_BYLINE             = 80
+	IPTC_CITY               = 90
+	IPTC_COUNTRY            = 101
+	IPTC_HEADLINE           = 105
+	IPTC_CREDIT             = 110
+	IPTC_CAPTION            = 120
+)
+
+// Returns the iptc data of the photoshop resources, nil if there is none.
+func findIPTC(resources []byte) []byte {
+	for len(resources) >= 12 && bytes.HasPrefix(resources, []byte("8BIM")) {
+		id := binary.BigEndian.Uint16(resources[4:6])
+		// the name is a pascal string, padded to an even length
+		nameLength := int(resources[6]) + 1
+		nameLength += nameLength % 2
+		if len(resources) < 6+nameLength+4 {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint32(resources[6+nameLength:]))
+		start := 6 + nameLength + 4
+		if size < 0 || len(resources) < start+size {
+			return nil
+		}
+		if id == PHOTOSHOP_IPTC {
+			return resources[start : start+size]
+		}
+		// the data is padded to an even length, too
+		resources = resources[start+size+size%2:]
+	}
+	return nil
+}
+
+// Parses the iptc datasets of the photoshop resources. The text is utf-8, if record 1 says so or if it is valid
+// utf-8, otherwise ISO-8859-1 like in older tools.
+func parseIPTC(resources []byte) captionData {
+	var result captionData
+	data := findIPTC(resources)
+	datasets := make(map[int][][]byte)
+	declaredUTF8 := false
+	for len(data) >= 5 && data[0] == IPTC_TAG_MARKER {
+		record, dataset := int(data[1]), int(data[2])
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		data = data[5:]
+		if length&IPTC_EXTENDED_LENGTH != 0 {
+			// the length is in the next bytes, only used for large binary data
+			lengthSize := length &^ IPTC_EXTENDED_LENGTH
+			if lengthSize > 4 || len(data) < lengthSize {
+				break
+			}
+			length = 0
+			for _, b := range data[:lengthSize] {
+				length = length<<8 | int(b)
+			}
+			data = data[lengthSize:]
+		}
+		if length > len(data) {
+			break
+		}
+		value := data[:length]
+		data = data[length:]
+
+		if record == IPTC_RECORD_ENVELOPE && dataset == IPTC_CHARSET {
+			declaredUTF8 = string(value) == IPTC_UTF8
+		} else if record == IPTC_RECORD_APPLICATION {
+			datasets[dataset] = append(datasets[dataset], value)
+		}
+	}
+
+	text := func(dataset int) *string {
+		values := datasets[dataset]
+		if len(values) == 0 {
+			return nil
+		}
+		return nonEmpty(decodeIPTC(values[0], declaredUTF8))
+	}
+	result.Title = text(IPTC_OBJECT_NAME)
+	if result.Title == nil {
+		result.Title = text(IPTC_HEADLINE)
+	}
+	result.Description = text(IPTC_CAPTION)
+	result.Byline = text(IPTC_BYLINE)
+	result.Credit = text(IPTC_CREDIT)
+	result.City = text(IPTC_CITY)
+	result.Country = text(IPTC_COUNTRY)
+	for _, keyword := range datasets[IPTC_KEYWORDS] {
+		if value := decodeIPTC(keyword, declaredUTF8); value != "" {
+			result.Keywords = append(result.Keywords, value)
+		}
+	}
+	return result
+}
+
+// decodes the text as utf-8 or ISO-8859-1, see parseIPTC
+func decodeIPTC(value []byte, declaredUTF8 bool) string {
+	if declaredUTF8 || utf8.Valid(value) {
+		return strings.TrimSpace(strings.ToValidUTF8(string(value), ""))
+	}
+	// every byte of ISO-8859-1 is the unicode code point
+	runes := make([]rune, len(value))
+	for i, b := range value {
+		runes[i] = rune(b)
+	}
+	return strings.TrimSpace(string(runes))
+}
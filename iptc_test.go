@@ -0,0 +1,66 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// returns the APP13 payload with the iptc datasets, each is record, dataset and value
+func testIPTC(datasets ...[]interface{}) []byte {
+	var iptc []byte
+	for _, dataset := range datasets {
+		value := dataset[2].(string)
+		iptc = append(iptc, IPTC_TAG_MARKER, byte(dataset[0].(int)), byte(dataset[1].(int)),
+			byte(len(value)>>8), byte(len(value)))
+		iptc = append(iptc, value...)
+	}
+	// an other resource before the iptc resource
+	resources := []byte("8BIM\x04\x0c\x00\x00\x00\x00\x00\x01x\x00")
+	resources = append(resources, "8BIM\x04\x04\x00\x00"...)
+	resources = append(resources, byte(len(iptc)>>24), byte(len(iptc)>>16), byte(len(iptc)>>8), byte(len(iptc)))
+	return append([]byte(PHOTOSHOP_HEADER), append(resources, iptc...)...)
+}
+
+func Test_parseIPTC(t *testing.T) {
+	// ISO-8859-1
+	data := testIPTC(
+		[]interface{}{2, IPTC_CAPTION, "Gr\xfc\xdfe aus K\xf6ln"},
+		[]interface{}{2, IPTC_KEYWORDS, "Dom"},
+		[]interface{}{2, IPTC_KEYWORDS, "Rhein"},
+		[]interface{}{2, IPTC_BYLINE, "A. Photographer"},
+		[]interface{}{2, IPTC_CITY, "K\xf6ln"},
+	)
+	captions := parseIPTC(data[len(PHOTOSHOP_HEADER):])
+	require.Equal(t, "Grüße aus Köln", *captions.Description)
+	require.Equal(t, []string{"Dom", "Rhein"}, captions.Keywords)
+	require.Equal(t, "A. Photographer", *captions.Byline)
+	require.Equal(t, "Köln", *captions.City)
+	require.Nil(t, captions.Title)
+
+	// utf-8, declared in record 1, the headline is the title without object name
+	data = testIPTC([]interface{}{1, IPTC_CHARSET, IPTC_UTF8}, []interface{}{2, IPTC_HEADLINE, "Köln"})
+	require.Equal(t, "Köln", *parseIPTC(data[len(PHOTOSHOP_HEADER):]).Title)
+
+	require.Nil(t, parseIPTC([]byte("8BIM")).Description)
+}
+
+func Test_readCaptions_precedence(t *testing.T) {
+	iptc := testIPTC(
+		[]interface{}{2, IPTC_OBJECT_NAME, "IPTC title"},
+		[]interface{}{2, IPTC_CAPTION, "IPTC caption"},
+		[]interface{}{2, IPTC_CREDIT, "Agency"},
+	)
+	image := withSegment(testImage(t, 10, 10), 0xED, iptc)
+	image = withEmbeddedXMP(image, testXMP(`>
+    <dc:title><rdf:Alt><rdf:li xml:lang="x-default">XMP title</rdf:li></rdf:Alt></dc:title>
+  </rdf:Description>`))
+	source := fstest.MapFS{"a.jpg": image}
+
+	captions, err := readCaptions(source, "a.jpg")
+	require.NoError(t, err)
+	require.Equal(t, "XMP title", *captions.Title)
+	require.Equal(t, "IPTC caption", *captions.Description)
+	require.Equal(t, "Agency", *captions.Credit)
+}
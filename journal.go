@@ -0,0 +1,207 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// the journal is written at most this often
+const JOURNAL_FLUSH_INTERVAL = 10 * time.Second
+
+// journal records the progress of a run in the output, so an interrupted run can resume where it stopped: the
+// metadata of the images and the finished thumbnails of the unfinished albums and the finished albums. The entries
+// of an album are dropped, when its meta files are written. The journal is removed after the run.
+// All methods can be called on a nil journal, which records nothing.
+type journal struct {
+	out OutputFS
+	// the start of the first, interrupted run. Thumbnails written after it are only complete, if they are recorded.
+	started    time.Time
+	albums     map[string]bool
+	images     map[string]journalImage
+	thumbnails map[string]bool
+	dirty      bool
+	lastFlush  time.Time
+}
+
+// the file format of the journal
+type journalData struct {
+	Started    time.Time               `json:"started"`
+	Albums     []string                `json:"albums"`
+	Images     map[string]journalImage `json:"images"`
+	Thumbnails []string                `json:"thumbnails"`
+}
+
+type journalImage struct {
+	MetaJsonImage
+	// isn't part of the meta files
+	Rotate RotationAction `json:"rotate"`
+}
+
+// Reads the journal of an interrupted run or starts a new one.
+func openJournal(out OutputFS) (*journal, error) {
+	j := &journal{
+		out:        out,
+		started:    time.Now(),
+		albums:     make(map[string]bool),
+		images:     make(map[string]journalImage),
+		thumbnails: make(map[string]bool),
+		lastFlush:  time.Now(),
+	}
+	data, err := fs.ReadFile(out, JOURNAL_NAME)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var content journalData
+	if err := json.Unmarshal(data, &content); err != nil {
+		// e.g. the run was killed while writing the journal
+		log.WithError(err).Warn("Ignoring the invalid journal")
+		return j, nil
+	}
+	j.started = content.Started
+	for _, album := range content.Albums {
+		j.albums[album] = true
+	}
+	for file, image := range content.Images {
+		j.images[file] = image
+	}
+	for _, thumbnail := range content.Thumbnails {
+		j.thumbnails[thumbnail] = true
+	}
+	log.WithFields(log.Fields{"started": j.started, "albums": len(j.albums), "images": len(j.images)}).
+		Info("Resuming the interrupted run")
+	return j, nil
+}
+
+// Returns the metadata of the image (path in the source), if it was read by the interrupted run.
+func (j *journal) image(file string) (MetaJsonImage, bool) {
+	if j == nil {
+		return MetaJsonImage{}, false
+	}
+	image, found := j.images[file]
+	image.MetaJsonImage.Rotate = image.Rotate
+	return image.MetaJsonImage, found
+}
+
+func (j *journal) addImage(file string, image MetaJsonImage) {
+	if j == nil {
+		return
+	}
+	j.images[file] = journalImage{image, image.Rotate}
+	j.changed()
+}
+
+func (j *journal) addThumbnail(thumbnail string) {
+	if j == nil {
+		return
+	}
+	j.thumbnails[thumbnail] = true
+	j.changed()
+}
+
+// true, if the existing thumbnail may be incomplete: it was written by the interrupted run, but not recorded.
+func (j *journal) isPartial(thumbnail string, info fs.FileInfo) bool {
+	if j == nil || j.thumbnails[thumbnail] || info.ModTime().Before(j.started) {
+		return false
+	}
+	return !j.albums[path.Dir(path.Dir(thumbnail))]
+}
+
+// Records the album (path in the source) as finished, its meta files are written.
+func (j *journal) albumDone(album string) {
+	if j == nil {
+		return
+	}
+	j.albums[album] = true
+	for file := range j.images {
+		if path.Dir(file) == album {
+			delete(j.images, file)
+		}
+	}
+	for thumbnail := range j.thumbnails {
+		if path.Dir(path.Dir(thumbnail)) == album {
+			delete(j.thumbnails, thumbnail)
+		}
+	}
+	j.changed()
+}
+
+func (j *journal) changed() {
+	j.dirty = true
+	if time.Since(j.lastFlush) >= JOURNAL_FLUSH_INTERVAL {
+		j.flush()
+	}
+}
+
+// Writes the journal, if it has changed. An error is only logged, the run goes on without the journal.
+func (j *journal) flush() {
+	if j == nil || !j.dirty {
+		return
+	}
+	content := journalData{Started: j.started, Albums: []string{}, Images: j.images, Thumbnails: []string{}}
+	for album := range j.albums {
+		content.Albums = append(content.Albums, album)
+	}
+	for thumbnail := range j.thumbnails {
+		content.Thumbnails = append(content.Thumbnails, thumbnail)
+	}
+	sort.Strings(content.Albums)
+	sort.Strings(content.Thumbnails)
+
+	data, err := json.Marshal(content)
+	if err == nil {
+		err = j.out.WriteFile(JOURNAL_NAME, data)
+	}
+	if err != nil {
+		log.WithError(err).Warn("Can't write the journal")
+	}
+	j.dirty = false
+	j.lastFlush = time.Now()
+}
+
+// Ends the run of the folder (path in the source): all its entries are dropped. The journal is removed, if it's
+// empty, otherwise the entries of other folders are kept for the next run.
+func (j *journal) close(folder string) {
+	if j == nil {
+		return
+	}
+	inside := func(name string) bool {
+		return folder == "." || name == folder || strings.HasPrefix(name, folder+"/")
+	}
+	for album := range j.albums {
+		if inside(album) {
+			delete(j.albums, album)
+		}
+	}
+	for file := range j.images {
+		if inside(file) {
+			delete(j.images, file)
+		}
+	}
+	for thumbnail := range j.thumbnails {
+		if inside(thumbnail) {
+			delete(j.thumbnails, thumbnail)
+		}
+	}
+
+	if len(j.albums) > 0 || len(j.images) > 0 || len(j.thumbnails) > 0 {
+		j.dirty = true
+		j.flush()
+		return
+	}
+	if found, _ := exists(j.out, JOURNAL_NAME); found {
+		if err := j.out.Remove(JOURNAL_NAME); err != nil {
+			log.WithError(err).Warn("Can't remove the journal")
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// available log formats
+var LOG_FORMATS = [...]string{"text", "json"}
+
+// Configures the global logger.
+// level - error, warn, info or debug
+// format - text or json. The json format writes one object per line with all fields, e.g. album, file, size, duration.
+func SetupLogging(level string, format string) error {
+	logLevel, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(logLevel)
+
+	switch format {
+	case LOG_FORMATS[0]:
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	case LOG_FORMATS[1]:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Writes recursively the meta files of the folder. The metadata of all images must be read before.
+func WriteMetaFiles(folder *FolderContent, opts *Options, out MetaWriter) error {
+	if err := writeFolderMetaFiles(folder, opts, out); err != nil {
+		return err
+	}
+	for i := range folder.Folder {
+		if err := WriteMetaFiles(&folder.Folder[i], opts, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the meta files of the folder without its sub folders. The metadata of all images and the time of the sub
+// folders must be read before.
+func writeFolderMetaFiles(folder *FolderContent, opts *Options, out MetaWriter) error {
+	log.WithField("album", folder.FullPath).Debug("Writing meta file")
+	meta := MetaJson{}
+	meta.Images = make([]MetaJsonImage, len(folder.Files))
+	for i, imgFile := range folder.Files {
+		imgMeta, found := folder.ImageMetadata[imgFile]
+		if !found {
+			return fmt.Errorf("no metadata for the image %s in %s", imgFile, folder.FullPath)
+		}
+		meta.Images[i] = opts.exportImage(imgMeta)
+	}
+
+	SortImages(opts.Order, meta.Images)
+
+	meta.Meta.Title = folder.GetFolderTitle()
+	meta.Meta.Description = folder.Config.Description
+	meta.Meta.Place = folder.Place
+
+	meta.SubDirs = make([]MetaJsonSubDir, len(folder.Folder))
+	for i := range folder.Folder {
+		meta.SubDirs[i] = NewSubDirEntry(&folder.Folder[i])
+	}
+
+	// all sub dirs are read -> sets the time
+	meta.Meta.Time = folder.Time // default
+
+	sort.Sort(ByTimeDesc{meta.SubDirs})
+
+	if err := writeMetaJsonFiles(out, opts, folder.FullPath, meta); err != nil {
+		return err
+	}
+	if err := writeAsJson(out, newAlbumState(opts, folder), path.Join(folder.FullPath, STATE_NAME)); err != nil {
+		return err
+	}
+	if opts.GeoJSON {
+		if err := writeGeoJson(out, opts, folder.FullPath, meta); err != nil {
+			return err
+		}
+	}
+
+	if opts.CCSize > 0 {
+		return writeChromecastMetaFile(out, opts.CCSize, meta.Images, folder)
+	}
+	return nil
+}
+
+// writes the meta.json and, if requested, the first/last x meta files of a folder
+func writeMetaJsonFiles(out MetaWriter, opts *Options, folderPath string, meta MetaJson) error {
+	if err := writeAsJson(out, meta, path.Join(folderPath, META_NAME)); err != nil {
+		return err
+	}
+
+	if opts.FirstXMeta > 0 {
+		end := opts.FirstXMeta
+		if end > len(meta.Images) {
+			end = len(meta.Images)
+		}
+		firstXMeta := MetaJson{
+			Meta:    meta.Meta,
+			SubDirs: meta.SubDirs,
+			Images:  meta.Images[0:end],
+		}
+		if err := writeAsJson(out, firstXMeta, path.Join(folderPath, META_NAME_FIRST_X)); err != nil {
+			return err
+		}
+	}
+
+	if opts.LastXMeta > 0 {
+		start := len(meta.Images) - opts.LastXMeta
+		if start < 0 {
+			start = 0
+		}
+		lastXMeta := MetaJson{
+			Meta:    meta.Meta,
+			SubDirs: meta.SubDirs,
+			Images:  meta.Images[start:],
+		}
+		if err := writeAsJson(out, lastXMeta, path.Join(folderPath, META_NAME_LAST_X)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAsJson(out MetaWriter, jsonData interface{}, target string) error {
+	bytes, err := json.Marshal(jsonData)
+	if err != nil {
+		return err
+	}
+	return out.WriteFile(target, bytes)
+}
+
+func writeChromecastMetaFile(out MetaWriter, ccSize int, images []MetaJsonImage, folder *FolderContent) error {
+	log.WithField("album", folder.FullPath).Debug("Writing Chromecast meta file")
+	var ccImages = make([]ChromecastImage, len(images))
+	for i, image := range images {
+		filename := fmt.Sprintf("%s/%d-%s", THUMB_DIR, ccSize, image.Filename)
+		ccImages[i] = ChromecastImage{filename, image.Width, image.Height, image.Exif.Time}
+	}
+
+	ccFilename := path.Join(folder.FullPath, META_NAME_CHROMECAST)
+	bytes, err := json.Marshal(ccImages)
+	if err != nil {
+		return err
+	}
+	jsonp := append([]byte(CC_PREFIX), bytes...)
+	jsonp = append(jsonp, []byte(CC_SUFFIX)...)
+	return out.WriteFile(ccFilename, jsonp)
+}
@@ -0,0 +1,89 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	METRICS_NAMESPACE = "makemeta"
+	// the phase label of Failures for a failed run or rebuild job, the other phases are the PHASE_ constants
+	FAILURE_RUN = "run"
+)
+
+// The registry with all metrics of the generator. It contains no go runtime metrics, so the metrics can be written
+// to a node exporter textfile.
+var MetricsRegistry = prometheus.NewRegistry()
+
+var (
+	ImagesScanned = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "images_scanned_total",
+		Help:      "The amount of images found while reading the folders.",
+	})
+
+	MetadataReads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "metadata_reads_total",
+		Help:      "The amount of images, which metadata was read.",
+	})
+
+	PhaseDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "phase_duration_seconds",
+		Help:      "The duration of the phase in the last run.",
+	}, []string{"phase"})
+
+	ThumbnailsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "thumbnails_created_total",
+		Help:      "The amount of created thumbnails per size.",
+	}, []string{"size"})
+
+	Failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "failures_total",
+		Help:      "The amount of failures per phase, the failed runs have the phase run.",
+	}, []string{"phase"})
+
+	ThumbnailWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "thumbnail_workers",
+		Help:      "The amount of running thumbnail workers.",
+	})
+
+	ThumbnailWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "thumbnail_workers_busy",
+		Help:      "The amount of thumbnail workers, which are creating a thumbnail right now.",
+	})
+
+	ThumbnailWorkerBusySeconds = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "thumbnail_worker_busy_seconds_total",
+		Help:      "The time all thumbnail workers spent creating thumbnails.",
+	})
+)
+
+func init() {
+	MetricsRegistry.MustRegister(ImagesScanned, MetadataReads, PhaseDuration, ThumbnailsCreated, Failures,
+		ThumbnailWorkers, ThumbnailWorkersBusy, ThumbnailWorkerBusySeconds)
+	// the failures should be visible before the first one
+	for _, phase := range []string{PHASE_SCAN, PHASE_METADATA, PHASE_THUMBNAILS, PHASE_META_FILES, FAILURE_RUN} {
+		Failures.WithLabelValues(phase)
+	}
+}
+
+// Counts a failure of the phase, e.g. FAILURE_RUN.
+func CountFailure(phase string) {
+	Failures.WithLabelValues(phase).Inc()
+}
+
+func countThumbnail(size int, err error) {
+	if err != nil {
+		CountFailure(PHASE_THUMBNAILS)
+		return
+	}
+	ThumbnailsCreated.WithLabelValues(strconv.Itoa(size)).Inc()
+}
@@ -0,0 +1,204 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// the jpeg quality of the thumbnails, if nothing else is set
+const DEFAULT_QUALITY = 75
+
+// Is called after every phase of a folder run, see the PHASE_ constants.
+type PhaseProgress func(phase string, duration time.Duration)
+
+// The options of the generator.
+type Options struct {
+	// the path to the images on the local disk, required without Source or Output
+	ImagePath string
+	// the images, the default is the image path
+	Source fs.FS
+	// the local folder for the meta files and thumbnails with the same album structure as the images, the default is
+	// the image path
+	OutputPath string
+	// the target of the meta files and thumbnails, the default is the output path
+	Output OutputFS
+	// the bounding boxes of the thumbnails (required)
+	Sizes IntList
+	// the jpeg quality (1-100) of the thumbnails, the default is DEFAULT_QUALITY
+	Quality int
+	// optional, the jpeg quality for single thumbnail sizes
+	SizeQuality map[int]int
+	// one of IMAGE_ORDER_FUNCTIONS, the default is the first one
+	Order string
+	// if > 0, creates a jsonp file for the Chromecast for this thumbnail size
+	CCSize int
+	// ignores the existing meta.json files
+	ForceUpdate bool
+	// the maximum amount of thumbnails created at the same time. Default is the number of cpu.
+	MaxThreads int
+	// if > 0, creates the additional file META_NAME_FIRST_X with the first X images
+	FirstXMeta int
+	// if > 0, creates the additional file META_NAME_LAST_X with the last X images
+	LastXMeta int
+	// the extended exif fields in the meta files, see EXIF_FIELDS. The default is none.
+	ExifFields []string
+	// writes a meta.geojson per album and the meta-albums.geojson of all albums in the root
+	GeoJSON bool
+	// reverse geocodes the gps positions with this GeoNames cities file, see loadPlaces. The default is none.
+	PlacesFile string
+	// loaded by Validate
+	places *placeIndex
+	// the time zone of the images without offset in the exif, e.g. "Europe/Berlin". The content.ini of a folder can
+	// override it. The default is none, the time is read as UTC.
+	TimeZone string
+	// loaded by Validate
+	timeZone *time.Location
+
+	// optional, is called after every phase
+	OnPhase PhaseProgress
+	// optional, is called after every created thumbnail
+	OnThumbnail ThumbnailProgress
+}
+
+// Checks the options and sets the defaults.
+func (o *Options) Validate() error {
+	if err := o.InitFileSystems(); err != nil {
+		return err
+	}
+	if len(o.Sizes) == 0 {
+		return errors.New("at least one thumbnail size is needed")
+	}
+	for _, size := range o.Sizes {
+		if size <= 0 {
+			return fmt.Errorf("invalid size: %d", size)
+		}
+	}
+
+	if o.Quality == 0 {
+		o.Quality = DEFAULT_QUALITY
+	}
+	if !isValidQuality(o.Quality) {
+		return fmt.Errorf("invalid quality: %d", o.Quality)
+	}
+	for size, quality := range o.SizeQuality {
+		if !isValidQuality(quality) {
+			return fmt.Errorf("invalid quality for the size %d: %d", size, quality)
+		}
+	}
+
+	if o.Order == "" {
+		o.Order = IMAGE_ORDER_FUNCTIONS[0]
+	}
+	if !IsValidOrder(o.Order) {
+		return fmt.Errorf("unknown order: %s", o.Order)
+	}
+
+	for _, field := range o.ExifFields {
+		if !IsValidExifField(field) {
+			return fmt.Errorf("unknown exif field: %s", field)
+		}
+	}
+
+	if o.TimeZone != "" {
+		var err error
+		if o.timeZone, err = time.LoadLocation(o.TimeZone); err != nil {
+			return fmt.Errorf("unknown time zone: %s", o.TimeZone)
+		}
+	}
+
+	if o.PlacesFile != "" && o.places == nil {
+		var err error
+		if o.places, err = loadPlaces(o.PlacesFile); err != nil {
+			return fmt.Errorf("can't read the places: %v", err)
+		}
+	}
+	return nil
+}
+
+// Sets the source and the output from the paths, if they are not set. Validate does this, too.
+func (o *Options) InitFileSystems() error {
+	if o.ImagePath == "" && (o.Source == nil || o.Output == nil) {
+		return errors.New("the image path is missing")
+	}
+	if o.Source == nil {
+		o.Source = NewDirFS(o.ImagePath)
+	}
+	if o.Output == nil {
+		if o.OutputPath == "" {
+			o.OutputPath = o.ImagePath
+		} else if isInside(o.ImagePath, o.OutputPath) {
+			return errors.New("the output path must not be inside of the image path")
+		}
+		o.Output = NewDirFS(o.OutputPath)
+	}
+	return nil
+}
+
+// Returns the image with only the configured exif fields.
+func (o *Options) exportImage(image MetaJsonImage) MetaJsonImage {
+	fields := make(map[string]bool, len(o.ExifFields))
+	for _, field := range o.ExifFields {
+		fields[field] = true
+	}
+	image.Exif = image.Exif.only(fields)
+	return image
+}
+
+// Returns all thumbnail sizes, inclusive the size for the Chromecast.
+func (o *Options) ThumbnailSizes() IntList {
+	if o.CCSize <= 0 {
+		return o.Sizes
+	}
+	for _, size := range o.Sizes {
+		if size == o.CCSize {
+			return o.Sizes
+		}
+	}
+	return append(append(IntList{}, o.Sizes...), o.CCSize)
+}
+
+// Returns the jpeg quality of the thumbnail size.
+func (o *Options) ThumbnailQuality(size int) int {
+	if quality, found := o.SizeQuality[size]; found {
+		return quality
+	}
+	if o.Quality == 0 {
+		return DEFAULT_QUALITY
+	}
+	return o.Quality
+}
+
+func isValidQuality(quality int) bool {
+	return quality >= 1 && quality <= 100
+}
+
+// Returns the name of the folder, the root is named like the image path.
+func (o *Options) folderName(folder string) string {
+	if folder == "." {
+		if o.ImagePath == "" {
+			return ""
+		}
+		return filepath.Base(o.ImagePath)
+	}
+	return path.Base(folder)
+}
+
+// true, if dir is inside of root, but not root itself
+func isInside(root string, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func IsValidOrder(value string) bool {
+	for _, order := range IMAGE_ORDER_FUNCTIONS {
+		if value == order {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_options_Validate(t *testing.T) {
+	opts := Options{ImagePath: "/images", Sizes: IntList{100}}
+	require.NoError(t, opts.Validate())
+	require.Equal(t, IMAGE_ORDER_FUNCTIONS[0], opts.Order)
+
+	require.Error(t, (&Options{Sizes: IntList{100}}).Validate())
+	require.Error(t, (&Options{ImagePath: "/images"}).Validate())
+	require.Error(t, (&Options{ImagePath: "/images", Sizes: IntList{0}}).Validate())
+	require.Error(t, (&Options{ImagePath: "/images", Sizes: IntList{100}, Order: "random"}).Validate())
+
+	require.NoError(t, (&Options{ImagePath: "/images", OutputPath: "/images-out", Sizes: IntList{100}}).Validate())
+	require.Error(t, (&Options{ImagePath: "/images", OutputPath: "/images/out", Sizes: IntList{100}}).Validate())
+
+	require.NoError(t, (&Options{ImagePath: "/images", Sizes: IntList{100}, ExifFields: []string{"iso"}}).Validate())
+	require.Error(t, (&Options{ImagePath: "/images", Sizes: IntList{100}, ExifFields: []string{"gps"}}).Validate())
+}
+
+func Test_options_exportImage(t *testing.T) {
+	iso, lens := 200, "50mm"
+	image := MetaJsonImage{Filename: "a.jpg", Exif: metaJsonExif{ISO: &iso, LensModel: &lens}}
+
+	require.Nil(t, (&Options{}).exportImage(image).Exif.ISO)
+	exported := (&Options{ExifFields: []string{"iso"}}).exportImage(image)
+	require.Equal(t, 200, *exported.Exif.ISO)
+	require.Nil(t, exported.Exif.LensModel)
+}
+
+func Test_Generate_newExifField(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		"A/a.jpg": withSegment(testImage(t, 10, 10), 0xE1, testExif(map[uint16]string{0xA434: "EF 50mm"})),
+	})
+	require.NoError(t, gallery.generate())
+	require.Nil(t, gallery.images("A")[0].Exif.LensModel)
+
+	// the previous meta file has no lens model
+	gallery.opts.ExifFields = []string{"lensModel"}
+	require.NoError(t, gallery.generate())
+	require.Equal(t, "EF 50mm", *gallery.images("A")[0].Exif.LensModel)
+	require.NotContains(t, string(gallery.out.MapFS["A/"+META_NAME].Data), "exifFields")
+
+	gallery.opts.ExifFields = nil
+	require.NoError(t, gallery.generate())
+	require.Nil(t, gallery.images("A")[0].Exif.LensModel)
+}
+
+func Test_options_ThumbnailSizes(t *testing.T) {
+	opts := Options{Sizes: IntList{100, 200}, CCSize: -1}
+	require.Equal(t, IntList{100, 200}, opts.ThumbnailSizes())
+
+	opts.CCSize = 200
+	require.Equal(t, IntList{100, 200}, opts.ThumbnailSizes())
+
+	opts.CCSize = 50
+	require.Equal(t, IntList{100, 200, 50}, opts.ThumbnailSizes())
+	require.Equal(t, IntList{100, 200}, opts.Sizes)
+}
+
+func Test_CleanAlbumPath(t *testing.T) {
+	for _, valid := range [][2]string{{"", ""}, {".", ""}, {"a/b/", "a/b"}, {"a/../b", "b"}} {
+		cleaned, err := CleanAlbumPath(valid[0])
+		require.NoError(t, err)
+		require.Equal(t, valid[1], cleaned)
+	}
+
+	for _, invalid := range []string{"..", "../a", "/a", "a/.thumbs", ".hidden"} {
+		_, err := CleanAlbumPath(invalid)
+		require.Error(t, err, invalid)
+	}
+}
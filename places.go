@@ -0,0 +1,197 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the country names of the GeoNames dump, read from the folder of the places file
+	COUNTRY_INFO_NAME = "countryInfo.txt"
+	// a position farther away from the nearest place gets no place, e.g. on the sea
+	PLACE_MAX_DISTANCE_KM = 50
+	EARTH_RADIUS_KM       = 6371
+)
+
+// placeIndex is a kd-tree of the places on the unit sphere for the nearest place of a gps position. The tree is
+// stored in the slice: the median of a range is its root, the lower and the upper half are its children.
+type placeIndex struct {
+	places []place
+	// the chord length of PLACE_MAX_DISTANCE_KM on the unit sphere
+	maxDistance float64
+}
+
+type place struct {
+	name    string
+	country string
+	point   [3]float64
+}
+
+// Reads the places from a GeoNames cities file like cities15000.txt (tab separated: id, name, ascii name, alternate
+// names, latitude, longitude, feature class, feature code, country code, ...). The country names are read from the
+// countryInfo.txt in the same folder, if it exists, otherwise the country code is used.
+func loadPlaces(placesFile string) (*placeIndex, error) {
+	countries, err := readCountries(filepath.Join(filepath.Dir(placesFile), COUNTRY_INFO_NAME))
+	if err != nil {
+		return nil, err
+	}
+
+	var places []place
+	err = readTabFile(placesFile, func(fields []string) error {
+		if len(fields) < 9 {
+			return fmt.Errorf("expected at least 9 columns, got %d", len(fields))
+		}
+		lat, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return err
+		}
+		lon, err := strconv.ParseFloat(fields[5], 64)
+		if err != nil {
+			return err
+		}
+		country, found := countries[fields[8]]
+		if !found {
+			country = fields[8]
+		}
+		places = append(places, place{name: fields[1], country: country, point: unitVector(lat, lon)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	index := &placeIndex{places: places, maxDistance: 2 * math.Sin(float64(PLACE_MAX_DISTANCE_KM)/EARTH_RADIUS_KM/2)}
+	index.build(0, len(places), 0)
+	log.WithFields(log.Fields{"file": placesFile, "places": len(places), "countries": len(countries)}).Info("Places loaded")
+	return index, nil
+}
+
+// reads the country names by their iso code, an empty map if the file doesn't exist
+func readCountries(countryFile string) (map[string]string, error) {
+	countries := make(map[string]string)
+	if _, err := os.Stat(countryFile); os.IsNotExist(err) {
+		return countries, nil
+	}
+	err := readTabFile(countryFile, func(fields []string) error {
+		if len(fields) < 5 {
+			return fmt.Errorf("expected at least 5 columns, got %d", len(fields))
+		}
+		countries[fields[0]] = fields[4]
+		return nil
+	})
+	return countries, err
+}
+
+// calls parse for every line, which isn't empty or a comment
+func readTabFile(file string, parse func(fields []string) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// the alternate names can be long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := parse(strings.Split(text, "\t")); err != nil {
+			return fmt.Errorf("invalid line %d in %s: %v", line, file, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func unitVector(lat float64, lon float64) [3]float64 {
+	latRad, lonRad := lat*math.Pi/180, lon*math.Pi/180
+	return [3]float64{math.Cos(latRad) * math.Cos(lonRad), math.Cos(latRad) * math.Sin(lonRad), math.Sin(latRad)}
+}
+
+func (p *place) distance(point [3]float64) float64 {
+	dx, dy, dz := p.point[0]-point[0], p.point[1]-point[1], p.point[2]-point[2]
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
+
+// sorts the range [from, to) into a kd-tree, the axis changes with every level
+func (i *placeIndex) build(from int, to int, axis int) {
+	if to-from <= 1 {
+		return
+	}
+	nodes := i.places[from:to]
+	sort.Slice(nodes, func(a, b int) bool {
+		return nodes[a].point[axis] < nodes[b].point[axis]
+	})
+	middle := from + (to-from)/2
+	i.build(from, middle, (axis+1)%3)
+	i.build(middle+1, to, (axis+1)%3)
+}
+
+// Returns the place of the position, nil if no place is near enough. Can be called on a nil index.
+func (i *placeIndex) lookup(gps *metaJsonGPS) *metaJsonPlace {
+	if i == nil || gps == nil || len(i.places) == 0 {
+		return nil
+	}
+	point := unitVector(gps.Latitude, gps.Longitude)
+	best, bestDistance := -1, i.maxDistance
+	i.nearest(0, len(i.places), 0, point, &best, &bestDistance)
+	if best < 0 {
+		return nil
+	}
+	return &metaJsonPlace{Name: i.places[best].name, Country: i.places[best].country}
+}
+
+func (i *placeIndex) nearest(from int, to int, axis int, point [3]float64, best *int, bestDistance *float64) {
+	if from >= to {
+		return
+	}
+	middle := from + (to-from)/2
+	if distance := i.places[middle].distance(point); distance <= *bestDistance {
+		*best, *bestDistance = middle, distance
+	}
+
+	// the half of the point first, the other half only, if it can be nearer
+	diff := point[axis] - i.places[middle].point[axis]
+	next := (axis + 1) % 3
+	if diff < 0 {
+		i.nearest(from, middle, next, point, best, bestDistance)
+		if -diff <= *bestDistance {
+			i.nearest(middle+1, to, next, point, best, bestDistance)
+		}
+	} else {
+		i.nearest(middle+1, to, next, point, best, bestDistance)
+		if diff <= *bestDistance {
+			i.nearest(from, middle, next, point, best, bestDistance)
+		}
+	}
+}
+
+// Returns the most frequent place of the images, the first one on a tie. nil, if no image has a place.
+func dominantPlace(images []MetaJsonImage) *metaJsonPlace {
+	counts := make(map[metaJsonPlace]int)
+	var dominant *metaJsonPlace
+	for _, image := range images {
+		if image.Place == nil {
+			continue
+		}
+		counts[*image.Place]++
+		if dominant == nil || counts[*image.Place] > counts[*dominant] {
+			dominant = image.Place
+		}
+	}
+	if dominant == nil {
+		return nil
+	}
+	result := *dominant
+	return &result
+}
@@ -0,0 +1,61 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadPlaces(t *testing.T) {
+	dir := t.TempDir()
+	cities := "2857458\tOldenburg\tOldenburg\t\t53.14118\t8.21467\tP\tPPLA2\tDE\n" +
+		"2944388\tBremen\tBremen\t\t53.07516\t8.80777\tP\tPPLA\tDE\n" +
+		"2759794\tAmsterdam\tAmsterdam\t\t52.37403\t4.88969\tP\tPPLC\tNL\n"
+	countries := "#ISO\tISO3\tISO-Numeric\tfips\tCountry\n" +
+		"DE\tDEU\t276\tGM\tGermany\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cities.txt"), []byte(cities), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, COUNTRY_INFO_NAME), []byte(countries), 0644))
+
+	places, err := loadPlaces(filepath.Join(dir, "cities.txt"))
+	require.NoError(t, err)
+	require.Equal(t, &metaJsonPlace{"Oldenburg", "Germany"}, places.lookup(&metaJsonGPS{Latitude: 53.15, Longitude: 8.3}))
+	require.Equal(t, &metaJsonPlace{"Bremen", "Germany"}, places.lookup(&metaJsonGPS{Latitude: 53.1, Longitude: 8.7}))
+	// without country name
+	require.Equal(t, &metaJsonPlace{"Amsterdam", "NL"}, places.lookup(&metaJsonGPS{Latitude: 52.4, Longitude: 4.9}))
+	// too far away
+	require.Nil(t, places.lookup(&metaJsonGPS{Latitude: 55, Longitude: 5}))
+	require.Nil(t, places.lookup(nil))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "invalid.txt"), []byte("1\tX\tX\t\tnorth\t8\tP\tPPL\tDE\n"), 0644))
+	_, err = loadPlaces(filepath.Join(dir, "invalid.txt"))
+	require.Error(t, err)
+}
+
+func Test_placeIndex_nearest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	index := &placeIndex{maxDistance: 2}
+	for i := 0; i < 1000; i++ {
+		index.places = append(index.places, place{point: unitVector(random.Float64()*180-90, random.Float64()*360-180)})
+	}
+	index.build(0, len(index.places), 0)
+
+	// the same as a linear search
+	for i := 0; i < 100; i++ {
+		point := unitVector(random.Float64()*180-90, random.Float64()*360-180)
+		best, bestDistance := -1, index.maxDistance
+		index.nearest(0, len(index.places), 0, point, &best, &bestDistance)
+		for j := range index.places {
+			require.True(t, index.places[j].distance(point) >= bestDistance)
+		}
+	}
+}
+
+func Test_dominantPlace(t *testing.T) {
+	a, b := &metaJsonPlace{"A", "X"}, &metaJsonPlace{"B", "X"}
+	require.Nil(t, dominantPlace([]MetaJsonImage{{}}))
+	require.Equal(t, b, dominantPlace([]MetaJsonImage{{Place: a}, {Place: b}, {}, {Place: b}}))
+	require.Equal(t, a, dominantPlace([]MetaJsonImage{{Place: a}, {Place: b}}))
+}
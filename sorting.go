@@ -1,8 +1,9 @@
 package mfGalleryMetaCreatorGo
 
 import (
-	"log"
 	"sort"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // available image order functions
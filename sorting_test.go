@@ -32,7 +32,7 @@ func Test_sorting_MetaJsonImage(t *testing.T) {
 }
 
 func makeTestData(filename string, time int64) MetaJsonImage {
-	return MetaJsonImage{filename, 100, 200, metaJsonExif{nil, nil, &time}, 0}
+	return MetaJsonImage{Filename: filename, Width: 100, Height: 200, Exif: metaJsonExif{Time: &time}}
 }
 
 func assertExif(t *testing.T, data []MetaJsonImage, expected []int) {
@@ -0,0 +1,66 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// albumState is what the next run of an album needs to know about the previous one, besides the meta files: it reads
+// the images again, if the state changed. It's written next to the meta.json as STATE_NAME, which isn't public.
+type albumState struct {
+	// the content.ini and the captions.txt of the folder, a removed one changes the metadata of the images
+	ConfigFiles []string `json:"configFiles,omitempty"`
+	// the extended exif fields (Options.ExifFields), the previous meta file has only these
+	ExifFields []string `json:"exifFields,omitempty"`
+	// the time zone of the images without offset, from the content.ini or -time-zone, "" is UTC
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Returns the state of the album, which is written with its meta files.
+func newAlbumState(opts *Options, folder *FolderContent) albumState {
+	state := albumState{ConfigFiles: folder.ConfigFiles, ExifFields: opts.ExifFields}
+	if timeZone := folder.Config.timeZone(opts.timeZone); timeZone != nil {
+		state.TimeZone = timeZone.String()
+	}
+	return state
+}
+
+// Reads the state of the previous run of the album, an empty state if there is none.
+func readAlbumState(fsys fs.FS, folder string) (albumState, error) {
+	var state albumState
+	stateFile := path.Join(folder, STATE_NAME)
+	if found, err := exists(fsys, stateFile); !found {
+		return state, err
+	}
+	data, err := fs.ReadFile(fsys, stateFile)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("invalid json in file %s: %v", stateFile, err)
+	}
+	return state, nil
+}
+
+// Returns true, if the metadata of the images read with the previous state differs.
+func (s albumState) changed(prev albumState) bool {
+	// a removed config file, e.g. the captions.txt
+	for _, name := range prev.ConfigFiles {
+		if !containsString(s.ConfigFiles, name) {
+			return true
+		}
+	}
+	// another time zone, e.g. a changed -time-zone
+	if s.TimeZone != prev.TimeZone {
+		return true
+	}
+	// a new exif field
+	for _, field := range s.ExifFields {
+		if !containsString(prev.ExifFields, field) {
+			return true
+		}
+	}
+	return false
+}
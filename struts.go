@@ -5,6 +5,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type IntList []int
@@ -24,6 +25,7 @@ func (i *IntList) Set(value string) error {
 }
 
 type FolderContent struct {
+	// the path in the source file system, "." is the root
 	FullPath      string
 	Name          string
 	Time          *int64
@@ -32,6 +34,12 @@ type FolderContent struct {
 	Files         []string
 	ImageMetadata map[string]MetaJsonImage
 	Folder        []FolderContent
+	// the most frequent place of the images of the folder without its sub folders
+	Place *metaJsonPlace
+	// the gpx files in the source: the files of the folder and of the content.ini
+	Tracks []string
+	// the names of the content.ini and the captions.txt, if they exist
+	ConfigFiles []string
 }
 
 func (fc *FolderContent) GetFullPathFile(file string) string {
@@ -59,7 +67,7 @@ func (fc *FolderContent) StringWithIntent(intention int) string {
 
 	return fmt.Sprintf(`%sfullPath: %s
 %sfolderName: %s
-%sconfig: %s
+%sconfig: %v
 %sfiles: len -> %d
 %stime: %d
 %stitle: %s
@@ -81,6 +89,25 @@ type FolderConfig struct {
 	Description string
 	// sets the cover image for the album. The default is the first image in the album.
 	Cover string
+	// writes the gps position of the images. The default is false, the position is never written.
+	GPS bool
+	// the decimal places of the gps position, e.g. 2 is about 1 km. -1 is the full precision.
+	GPSPrecision int
+	// more gpx tracks (relative to the folder) for the images without gps position, besides the gpx files in the folder
+	GPX []string
+	// corrects the camera clock for the tracks: this is added to the capture time of the camera clock (without the
+	// clock rules), e.g. -2h for a camera in CEST without time zone
+	GPXOffset time.Duration
+	// the maximum time between two track points, 0 is GPX_MAX_GAP
+	GPXMaxGap time.Duration
+	// the time zone of the images without offset in the exif. The default is Options.TimeZone.
+	TimeZone *time.Location
+	// the corrections of the camera clocks by lower case camera, from the [clock] section, see addClockRule
+	Clock map[string]time.Duration
+	// the captions and the alt texts by image file name, from the [images] section or the captions.txt. They
+	// override the description and the alt text of the image.
+	Captions map[string]string
+	AltTexts map[string]string
 }
 
 type MetaJson struct {
@@ -93,6 +120,8 @@ type MetaJsonMeta struct {
 	Title       string `json:"title"`
 	Time        *int64 `json:"time"`
 	Description string `json:"description"`
+	// the most frequent place of the images, only with Options.PlacesFile
+	Place *metaJsonPlace `json:"place,omitempty"`
 }
 
 type RotationAction int
@@ -110,6 +139,25 @@ type MetaJsonImage struct {
 	Height   int            `json:"height"`
 	Exif     metaJsonExif   `json:"exif"`
 	Rotate   RotationAction `json:"-"`
+	// the nearest place of the gps position, only with Options.PlacesFile
+	Place *metaJsonPlace `json:"place,omitempty"`
+	// from the xmp or iptc data, see readCaptions
+	Title       *string  `json:"title,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	// 1 to 5 stars, 0 is unrated and -1 is rejected
+	Rating *int `json:"rating,omitempty"`
+	// the color label, e.g. "Red"
+	Label *string `json:"label,omitempty"`
+	// the photographer
+	Byline *string `json:"byline,omitempty"`
+	// the provider, e.g. the press agency
+	Credit *string `json:"credit,omitempty"`
+	// as written by the photographer, the place is looked up from the gps position
+	City    *string `json:"city,omitempty"`
+	Country *string `json:"country,omitempty"`
+	// the alternative text for screen readers, only from the folder config
+	Alt *string `json:"alt,omitempty"`
 }
 
 type MetaJsonSubDir struct {
@@ -118,13 +166,60 @@ type MetaJsonSubDir struct {
 	Time       *int64  `json:"time"`
 	Cover      *string `json:"cover"`
 	ImageCount int     `json:"imageCount"`
+	// the place of the album, see MetaJsonMeta
+	Place *metaJsonPlace `json:"place,omitempty"`
 }
 
 type metaJsonExif struct {
 	// all values are optional
 	Make  *string `json:"make"`
 	Model *string `json:"model"`
-	Time  *int64  `json:"time"`
+	// unix ms
+	Time *int64 `json:"time"`
+	// the utc offset of the local capture time, e.g. "+02:00". Only, if the time zone is known.
+	TimeOffset *string `json:"timeOffset,omitempty"`
+	// the time of the camera clock, only if a clock rule of the content.ini corrected the time, see FolderConfig.Clock
+	OriginalTime *int64 `json:"originalTime,omitempty"`
+
+	// the extended fields are only written, if they are in Options.ExifFields, see EXIF_FIELDS
+	// e.g. "1/250" or "2.5" (seconds)
+	ExposureTime *string  `json:"exposureTime,omitempty"`
+	FNumber      *float64 `json:"fNumber,omitempty"`
+	ISO          *int     `json:"iso,omitempty"`
+	// in mm
+	FocalLength     *float64 `json:"focalLength,omitempty"`
+	FocalLength35mm *int     `json:"focalLength35mm,omitempty"`
+	LensModel       *string  `json:"lensModel,omitempty"`
+	// true, if the flash fired
+	Flash *bool `json:"flash,omitempty"`
+	// "auto" or "manual"
+	WhiteBalance *string `json:"whiteBalance,omitempty"`
+	// e.g. "aperture priority"
+	ExposureProgram *string `json:"exposureProgram,omitempty"`
+	Artist          *string `json:"artist,omitempty"`
+	Copyright       *string `json:"copyright,omitempty"`
+
+	// only, if it's enabled in the content.ini of the folder, see FolderConfig.GPS
+	GPS *metaJsonGPS `json:"gps,omitempty"`
+
+	// for the clock rules, it's never written
+	bodySerial *string
+}
+
+type metaJsonPlace struct {
+	Name    string `json:"name"`
+	Country string `json:"country"`
+}
+
+type metaJsonGPS struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// in meters above sea level
+	Altitude *float64 `json:"altitude,omitempty"`
+	// the direction of the camera in degrees, 0 is north
+	Direction *float64 `json:"direction,omitempty"`
+	// GPS_SOURCE_GPX, if the position is interpolated from a gpx track. Empty for the position of the exif data.
+	Source string `json:"source,omitempty"`
 }
 
 type ChromecastImage struct {
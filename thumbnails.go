@@ -1,30 +1,65 @@
 package mfGalleryMetaCreatorGo
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
 	"path"
 
 	"runtime"
+	"time"
 
 	"github.com/pixiv/go-libjpeg/jpeg"
 	"github.com/disintegration/imaging"
+	log "github.com/sirupsen/logrus"
 	"image"
 	"bufio"
 )
 
 type payload struct {
+	source         fs.FS
+	out            OutputFS
 	input          string
 	output         string
 	size           int
+	quality        int
 	rotationAction RotationAction
 }
 
-// Creates thumbnails recursively for the given folder using a thread pool with NumCPU of threads.
+// Creates thumbnails recursively for the given folder using a thread pool with opts.MaxThreads of threads.
+// opts - the thumbnails are created for opts.ThumbnailSizes(). The size represents the maximum bounding box.
 // folder - works on this folder
-// sizeList - creates thumbnails for this sizes. The size represents the maximum bounding box.
-func UpdateThumbnails(folder *FolderContent, sizeList IntList, maxThreads int) {
+// Returns the first error, a failed thumbnail doesn't stop the others.
+func UpdateThumbnails(opts *Options, folder *FolderContent) error {
+	pool := NewThumbnailPool(opts.MaxThreads)
+	defer pool.Close()
+
+	return pool.Update(context.Background(), opts, folder)
+}
+
+// Is called after every created thumbnail. err is the error of this thumbnail, if any.
+type ThumbnailProgress func(done int, total int, err error)
+
+// A pool of thumbnail workers, which can be used for many folders one after another.
+type ThumbnailPool struct {
+	jobs       chan poolJob
+	workerDone chan bool
+	maxWorker  int
+}
+
+type poolJob struct {
+	payload
+	result chan<- poolResult
+}
+
+type poolResult struct {
+	job payload
+	err error
+}
+
+// Starts a pool with the given amount of workers. The default is the number of cpu.
+func NewThumbnailPool(maxThreads int) *ThumbnailPool {
 	var maxWorker int
 	if maxThreads <= 0 {
 		maxWorker = runtime.GOMAXPROCS(runtime.NumCPU())
@@ -32,76 +67,201 @@ func UpdateThumbnails(folder *FolderContent, sizeList IntList, maxThreads int) {
 		maxWorker = maxThreads
 	}
 
-	jobs := make(chan payload)
-	workerDone := make(chan bool)
-
+	pool := &ThumbnailPool{
+		jobs:       make(chan poolJob),
+		workerDone: make(chan bool),
+		maxWorker:  maxWorker,
+	}
 	for workerId := 1; workerId <= maxWorker; workerId++ {
-		go thumbnailWorker(workerId, jobs, workerDone)
+		go thumbnailWorker(workerId, pool.jobs, pool.workerDone)
 	}
+	ThumbnailWorkers.Add(float64(maxWorker))
+	return pool
+}
 
-	addThumbnailJobs(folder, sizeList, jobs)
-
+// Stops all workers. Must not be called while Update is running.
+func (pool *ThumbnailPool) Close() {
 	// no more jobs coming in
-	close(jobs)
+	close(pool.jobs)
 
 	// waiting on the worker to finish
-	for workerId := 1; workerId <= maxWorker; workerId++ {
-		<-workerDone
+	for workerId := 1; workerId <= pool.maxWorker; workerId++ {
+		<-pool.workerDone
+	}
+	ThumbnailWorkers.Sub(float64(pool.maxWorker))
+}
+
+// Creates the missing thumbnails recursively for the given folder and waits until all are done. A failed thumbnail
+// doesn't stop the others, the first error is returned. No more thumbnails are started, after the context is done.
+// opts.OnThumbnail is called after every thumbnail.
+func (pool *ThumbnailPool) Update(ctx context.Context, opts *Options, folder *FolderContent) error {
+	var jobs []payload
+	if err := addThumbnailJobs(opts, folder, &jobs); err != nil {
+		return err
 	}
+	return pool.update(ctx, opts, jobs, nil)
 }
 
-func thumbnailWorker(id int, jobs <-chan payload, done chan<- bool) {
+// Creates the thumbnails and waits until all are done. onDone is called after every thumbnail, if not nil.
+func (pool *ThumbnailPool) update(ctx context.Context, opts *Options, jobs []payload, onDone func(job payload, err error)) error {
+	progress := opts.OnThumbnail
+
+	results := make(chan poolResult)
+	var firstErr error
+	next, pending, done := 0, 0, 0
+	for {
+		if ctx.Err() != nil {
+			// don't start any new jobs
+			next = len(jobs)
+		}
+		if next == len(jobs) && pending == 0 {
+			break
+		}
+
+		var send chan<- poolJob
+		var cancel <-chan struct{}
+		var job poolJob
+		if next < len(jobs) {
+			send = pool.jobs
+			cancel = ctx.Done()
+			job = poolJob{jobs[next], results}
+		}
+
+		select {
+		case send <- job:
+			next++
+			pending++
+		case result := <-results:
+			pending--
+			done++
+			if result.err != nil && firstErr == nil {
+				firstErr = result.err
+			}
+			if onDone != nil {
+				onDone(result.job, result.err)
+			}
+			if progress != nil {
+				progress(done, len(jobs), result.err)
+			}
+		case <-cancel:
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+func thumbnailWorker(id int, jobs <-chan poolJob, done chan<- bool) {
 	counter := 0
 	for job := range jobs {
-		createThumbnail(job.input, job.output, job.size, job.rotationAction)
+		ThumbnailWorkersBusy.Inc()
+		start := time.Now()
+		err := createThumbnail(job.source, job.out, job.input, job.output, job.size, job.quality, job.rotationAction)
+		countThumbnail(job.size, err)
+		ThumbnailWorkerBusySeconds.Add(time.Since(start).Seconds())
+		ThumbnailWorkersBusy.Dec()
+		job.result <- poolResult{job.payload, err}
 		// this helps to reduce the max memory usage
 		runtime.GC()
 		counter++
 	}
-	log.Printf("Thumbnail worker (%d) finished. Jobs done: %d.", id, counter)
+	log.Debugf("Thumbnail worker (%d) finished. Jobs done: %d.", id, counter)
 	done <- true
 }
 
-func addThumbnailJobs(folder *FolderContent, sizeList IntList, jobs chan<- payload) {
-	thumbFolder := path.Join(folder.FullPath, THUMB_DIR)
-	if _, err := os.Stat(thumbFolder); os.IsNotExist(err) {
-		os.Mkdir(thumbFolder, 0755)
+func addThumbnailJobs(opts *Options, folder *FolderContent, jobs *[]payload) error {
+	missing, err := missingThumbnails(opts, folder, nil)
+	if err != nil {
+		return err
+	}
+	*jobs = append(*jobs, missing...)
+
+	for i := range folder.Folder {
+		if err := addThumbnailJobs(opts, &folder.Folder[i], jobs); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// Returns the paths of all thumbnails in the output, which have to be created for the given folder. Sub folders are
+// not included.
+func MissingThumbnails(opts *Options, folder *FolderContent) ([]string, error) {
+	jobs, err := missingThumbnails(opts, folder, nil)
+	targets := make([]string, len(jobs))
+	for i, job := range jobs {
+		targets[i] = job.output
+	}
+	return targets, err
+}
+
+// Returns the thumbnails of the folder, which don't exist in the output or are older than their image. An existing
+// thumbnail is created again, if isPartial (if not nil) returns true for it, e.g. because an interrupted run didn't
+// finish it.
+func missingThumbnails(opts *Options, folder *FolderContent, isPartial func(thumbnail string, info fs.FileInfo) bool) ([]payload, error) {
+	var jobs []payload
+	thumbFolder := path.Join(folder.FullPath, THUMB_DIR)
 	for _, imgFile := range folder.Files {
 		meta, _ := folder.ImageMetadata[imgFile]
 		fullPathImage := folder.GetFullPathFile(imgFile)
-		for _, size := range sizeList {
+		imageInfo, err := fs.Stat(opts.Source, fullPathImage)
+		if err != nil {
+			return nil, err
+		}
+		// the rotation isn't in the meta.json, the metadata of a previous run doesn't know it
+		rotation, rotationKnown := meta.Rotate, meta.Rotate != NO_ROTATION
+		for _, size := range opts.ThumbnailSizes() {
 			targetFile := path.Join(thumbFolder, fmt.Sprintf("%d-%s", size, imgFile))
-			if _, err := os.Stat(targetFile); os.IsNotExist(err) {
-				jobs <- payload{fullPathImage, targetFile, size, meta.Rotate}
+			info, err := fs.Stat(opts.Output, targetFile)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			// the thumbnail of a replaced image is older than the image
+			if err != nil || info.ModTime().Before(imageInfo.ModTime()) || (isPartial != nil && isPartial(targetFile, info)) {
+				if !rotationKnown {
+					rotation, rotationKnown = ReadRotation(opts.Source, fullPathImage), true
+				}
+				jobs = append(jobs, payload{opts.Source, opts.Output, fullPathImage, targetFile, size, opts.ThumbnailQuality(size), rotation})
 			}
 		}
 	}
+	return jobs, nil
+}
 
-	for i := range folder.Folder {
-		addThumbnailJobs(&folder.Folder[i], sizeList, jobs)
-	}
+// Creates a single thumbnail, e.g. on demand. input is read from the source, output is written to out.
+func CreateThumbnail(source fs.FS, out OutputFS, input string, output string, size int, quality int, rotationAction RotationAction) error {
+	err := createThumbnail(source, out, input, output, size, quality, rotationAction)
+	countThumbnail(size, err)
+	return err
 }
 
-func createThumbnail(input string, output string, size int, rotationAction RotationAction) {
-	log.Printf("Create thumbnail (%d) for %s (%d)\n", size, input, rotationAction)
+func createThumbnail(source fs.FS, out OutputFS, input string, output string, size int, quality int, rotationAction RotationAction) error {
+	logger := log.WithFields(log.Fields{"file": input, "size": size})
+	logger.WithField("rotation", rotationAction).Debug("Create thumbnail")
 	if size <= 0 {
-		log.Fatal("Invalid thumbnail size: ", size)
+		return fmt.Errorf("invalid thumbnail size: %d", size)
 	}
+	start := time.Now()
 
 	// https://github.com/libjpeg-turbo/libjpeg-turbo/issues/206#issuecomment-357151653
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
-	file, err := os.Open(input)
-	CheckError(err, "Can't open image file.")
+	file, err := source.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open image file: %v", err)
+	}
 	defer file.Close()
 
 	img, err := jpeg.Decode(file, &jpeg.DecoderOptions{ScaleTarget: image.Rectangle{
 		Min: image.Point{X: 0, Y: 0},
 		Max: image.Point{X: size, Y: size},
 	}})
-	CheckError(err, "Can't decode image file.")
+	if err != nil {
+		return fmt.Errorf("can't decode image file %s: %v", input, err)
+	}
 
 	img = imaging.Fit(img, size, size, imaging.Linear)
 
@@ -129,17 +289,29 @@ func createThumbnail(input string, output string, size int, rotationAction Rotat
 		}
 	}
 
-	out, err := os.Create(output)
-	CheckError(err, "Can't write jpeg file.")
-	defer out.Close()
+	target, err := out.Create(output)
+	if err != nil {
+		return fmt.Errorf("can't write jpeg file: %v", err)
+	}
 
-	w := bufio.NewWriter(out)
+	w := bufio.NewWriter(target)
 
 	if rgba == nil {
-		err = jpeg.Encode(w, img, &jpeg.EncoderOptions{Quality: 75})
+		err = jpeg.Encode(w, img, &jpeg.EncoderOptions{Quality: quality})
 	} else {
-		err = jpeg.Encode(w, rgba, &jpeg.EncoderOptions{Quality: 75})
+		err = jpeg.Encode(w, rgba, &jpeg.EncoderOptions{Quality: quality})
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// don't leave a broken thumbnail behind
+		out.Remove(output)
+		return fmt.Errorf("can't encode image file %s: %v", input, err)
 	}
-	CheckError(err, "Can't encode image file")
-	w.Flush()
+	logger.WithField("duration", time.Since(start).Seconds()).Debug("Thumbnail created")
+	return nil
 }
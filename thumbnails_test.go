@@ -0,0 +1,41 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a raw exif block with only the orientation in IFD0.
+func testOrientationExif(orientation uint16) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("Exif\x00\x00II*\x00")
+	binary.Write(&buf, binary.LittleEndian, []uint32{8})
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 0x0112, 3})
+	binary.Write(&buf, binary.LittleEndian, []uint32{1})
+	binary.Write(&buf, binary.LittleEndian, []uint16{orientation, 0})
+	binary.Write(&buf, binary.LittleEndian, []uint32{0})
+	return buf.Bytes()
+}
+
+func Test_Generate_rotatedThumbnailOfPrevRun(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		// rotated by 90° clockwise to be upright
+		"A/a.jpg": withSegment(testImage(t, 40, 20), 0xE1, testOrientationExif(6)),
+	})
+	gallery.opts.Sizes = IntList{16}
+	require.NoError(t, gallery.generate())
+
+	// the image isn't read again, only the thumbnail of the new size is created
+	gallery.opts.Sizes = IntList{16, 8}
+	require.NoError(t, gallery.generate())
+	for _, thumbnail := range []string{"A/.thumbs/16-a.jpg", "A/.thumbs/8-a.jpg"} {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(gallery.out.MapFS[thumbnail].Data))
+		require.NoError(t, err, thumbnail)
+		require.True(t, config.Height > config.Width, "%s: %dx%d", thumbnail, config.Width, config.Height)
+	}
+}
@@ -0,0 +1,77 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xor-gate/goexif2/exif"
+)
+
+const (
+	EXIF_TIME_LAYOUT = "2006:01:02 15:04:05"
+	// the format of the utc offset in the meta files, like in the exif
+	TIME_OFFSET_LAYOUT = "-07:00"
+)
+
+var exifOffsetPattern = regexp.MustCompile(`^([+-])(\d\d):(\d\d)$`)
+
+// Returns the zone of the offset tag, nil if it's missing or invalid, e.g. "   :  " of some cameras.
+func exifZone(x *exif.Exif, field exif.FieldName) *time.Location {
+	tag, err := x.Get(field)
+	if err != nil {
+		return nil
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return nil
+	}
+	match := exifOffsetPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return nil
+	}
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	seconds := hours*3600 + minutes*60
+	if match[1] == "-" {
+		seconds = -seconds
+	}
+	return time.FixedZone("", seconds)
+}
+
+// Returns the fraction of a second of the sub-seconds tag, e.g. "05" is 50 ms. 0, if it's missing or invalid.
+func exifSubSeconds(x *exif.Exif, field exif.FieldName) time.Duration {
+	tag, err := x.Get(field)
+	if err != nil {
+		return 0
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return 0
+	}
+	digits := strings.TrimSpace(value)
+	if digits == "" || len(digits) > 9 {
+		return 0
+	}
+	fraction, err := strconv.Atoi(digits)
+	if err != nil || fraction < 0 {
+		return 0
+	}
+	// to nanoseconds: "05" is 050000000
+	return time.Duration(fraction) * time.Duration(pow10(9-len(digits)))
+}
+
+func pow10(exp int) int {
+	result := 1
+	for i := 0; i < exp; i++ {
+		result *= 10
+	}
+	return result
+}
+
+// returns the utc offset of the time for the meta files, e.g. "+02:00"
+func timeOffset(t time.Time) *string {
+	offset := t.Format(TIME_OFFSET_LAYOUT)
+	return &offset
+}
@@ -0,0 +1,120 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xor-gate/goexif2/exif"
+)
+
+// Returns a raw exif block with the string tags in the exif sub-IFD.
+func testExif(tags map[uint16]string) []byte {
+	ids := make([]int, 0, len(tags))
+	for id := range tags {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	var buf bytes.Buffer
+	order := binary.LittleEndian
+	write := func(data interface{}) {
+		binary.Write(&buf, order, data)
+	}
+	buf.WriteString("Exif\x00\x00II*\x00")
+	write(uint32(8))
+	// IFD0 with the pointer to the exif sub-IFD at 26
+	write(uint16(1))
+	write([]uint16{0x8769, 4})
+	write([]uint32{1, 26, 0})
+
+	// the values follow the entries of the sub-IFD
+	valueOffset := 26 + 2 + 12*len(ids) + 4
+	var values bytes.Buffer
+	write(uint16(len(ids)))
+	for _, id := range ids {
+		value := tags[uint16(id)] + "\x00"
+		write([]uint16{uint16(id), 2})
+		write(uint32(len(value)))
+		if len(value) <= 4 {
+			var inline [4]byte
+			copy(inline[:], value)
+			buf.Write(inline[:])
+		} else {
+			write(uint32(valueOffset + values.Len()))
+			values.WriteString(value)
+		}
+	}
+	write(uint32(0))
+	buf.Write(values.Bytes())
+	return buf.Bytes()
+}
+
+func Test_getExifTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		tags        map[uint16]string
+		defaultZone *time.Location
+		want        string
+		wantKnown   bool
+	}{
+		{"utc", map[uint16]string{0x9003: "2021:07:01 12:00:00"}, nil, "2021-07-01T12:00:00Z", false},
+		{"default zone", map[uint16]string{0x9003: "2021:07:01 12:00:00"}, berlin, "2021-07-01T12:00:00+02:00", true},
+		{"offset", map[uint16]string{0x9003: "2021:07:01 12:00:00", 0x9011: "-05:30"}, berlin,
+			"2021-07-01T12:00:00-05:30", true},
+		{"invalid offset", map[uint16]string{0x9003: "2021:01:01 12:00:00", 0x9011: "   :  "}, berlin,
+			"2021-01-01T12:00:00+01:00", true},
+		{"sub-seconds", map[uint16]string{0x9003: "2021:07:01 12:00:00", 0x9011: "+00:00", 0x9291: "05"}, nil,
+			"2021-07-01T12:00:00.05Z", true},
+		// the offset of the DateTimeOriginal doesn't belong to the DateTime
+		{"date time", map[uint16]string{0x0132: "2021:07:01 12:00:00", 0x9010: "+01:00", 0x9011: "+03:00", 0x9290: "7"},
+			nil, "2021-07-01T12:00:00.7+01:00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := exif.Decode(bytes.NewReader(testExif(tt.tags)))
+			require.NoError(t, err)
+			got, known, err := getExifTime(x, tt.defaultZone)
+			require.NoError(t, err)
+			want, err := time.Parse(time.RFC3339Nano, tt.want)
+			require.NoError(t, err)
+			require.True(t, want.Equal(got), "got %v", got)
+			require.Equal(t, want.Format(TIME_OFFSET_LAYOUT), got.Format(TIME_OFFSET_LAYOUT))
+			require.Equal(t, tt.wantKnown, known)
+		})
+	}
+}
+
+func Test_ReadIniFile_timeZone(t *testing.T) {
+	source := fstest.MapFS{
+		"berlin/content.ini":  &fstest.MapFile{Data: []byte("timeZone=Europe/Berlin")},
+		"invalid/content.ini": &fstest.MapFile{Data: []byte("timeZone=Europe/Nowhere")},
+	}
+	config, err := ReadIniFile(source, "berlin/content.ini")
+	require.NoError(t, err)
+	require.Equal(t, "Europe/Berlin", config.TimeZone.String())
+
+	_, err = ReadIniFile(source, "invalid/content.ini")
+	require.Error(t, err)
+}
+
+func Test_Generate_changedTimeZone(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		"A/a.jpg": withSegment(testImage(t, 10, 10), 0xE1, testExif(map[uint16]string{0x9003: "2021:07:01 12:00:00"})),
+	})
+	require.NoError(t, gallery.generate())
+	require.Nil(t, gallery.images("A")[0].Exif.TimeOffset)
+
+	gallery.opts.TimeZone = "Europe/Berlin"
+	require.NoError(t, gallery.generate())
+	exif := gallery.images("A")[0].Exif
+	require.Equal(t, "+02:00", *exif.TimeOffset)
+	require.Equal(t, time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), *exif.Time)
+}
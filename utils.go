@@ -2,7 +2,7 @@ package mfGalleryMetaCreatorGo
 
 import (
 	"runtime/debug"
-	"log"
+	log "github.com/sirupsen/logrus"
 	"strings"
 )
 
@@ -11,5 +11,5 @@ func CheckError(err error, messages ...string) {
 		return
 	}
 	debug.PrintStack()
-	log.Fatal(strings.Join(messages, " "), "\nError: ", err)
+	log.WithError(err).Fatal(strings.Join(messages, " "))
 }
@@ -0,0 +1,130 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	XMP_SIDECAR_EXT = ".xmp"
+	// the header of the xmp segment of a jpeg
+	XMP_HEADER = "http://ns.adobe.com/xap/1.0/\x00"
+
+	NS_RDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	NS_DC  = "http://purl.org/dc/elements/1.1/"
+	NS_XMP = "http://ns.adobe.com/xap/1.0/"
+	NS_XML = "http://www.w3.org/XML/1998/namespace"
+
+	NS_PHOTOSHOP = "http://ns.adobe.com/photoshop/1.0/"
+)
+
+// true, if the file is a xmp sidecar
+func IsSidecarFile(name string) bool {
+	return strings.HasSuffix(name, XMP_SIDECAR_EXT)
+}
+
+// Returns the possible sidecars of the image: "a.jpg.xmp" (e.g. darktable) and "a.xmp" (e.g. Lightroom).
+func xmpSidecars(image string) []string {
+	return []string{image + XMP_SIDECAR_EXT, strings.TrimSuffix(image, path.Ext(image)) + XMP_SIDECAR_EXT}
+}
+
+// returns nil for an empty value
+func nonEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// Parses the fields of a xmp packet. The fields can be attributes of rdf:Description or elements.
+func parseXMP(data []byte) (captionData, error) {
+	var result captionData
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// the open elements
+	var stack []xml.Name
+	var text strings.Builder
+	var lang string
+
+	setText := func(name xml.Name, value string) {
+		value = strings.TrimSpace(value)
+		switch name {
+		case xml.Name{Space: NS_XMP, Local: "Rating"}:
+			if rating, err := strconv.ParseFloat(value, 64); err == nil {
+				stars := int(rating)
+				result.Rating = &stars
+			}
+		case xml.Name{Space: NS_XMP, Local: "Label"}:
+			result.Label = nonEmpty(value)
+		case xml.Name{Space: NS_PHOTOSHOP, Local: "Credit"}:
+			result.Credit = nonEmpty(value)
+		case xml.Name{Space: NS_PHOTOSHOP, Local: "City"}:
+			result.City = nonEmpty(value)
+		case xml.Name{Space: NS_PHOTOSHOP, Local: "Country"}:
+			result.Country = nonEmpty(value)
+		}
+	}
+	// the parent of the rdf:li, e.g. dc:title for dc:title/rdf:Alt/rdf:li
+	listField := func() xml.Name {
+		if len(stack) < 3 {
+			return xml.Name{}
+		}
+		return stack[len(stack)-3]
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name)
+			text.Reset()
+			lang = ""
+			for _, attr := range t.Attr {
+				if attr.Name == (xml.Name{Space: NS_XML, Local: "lang"}) {
+					lang = attr.Value
+				}
+				if t.Name == (xml.Name{Space: NS_RDF, Local: "Description"}) {
+					setText(attr.Name, attr.Value)
+				}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if t.Name == (xml.Name{Space: NS_RDF, Local: "li"}) && value != "" {
+				switch listField() {
+				case xml.Name{Space: NS_DC, Local: "title"}:
+					// the default language wins
+					if result.Title == nil || lang == "x-default" {
+						result.Title = &value
+					}
+				case xml.Name{Space: NS_DC, Local: "description"}:
+					if result.Description == nil || lang == "x-default" {
+						result.Description = &value
+					}
+				case xml.Name{Space: NS_DC, Local: "subject"}:
+					result.Keywords = append(result.Keywords, value)
+				case xml.Name{Space: NS_DC, Local: "creator"}:
+					// more creators are joined
+					if result.Byline != nil {
+						value = *result.Byline + ", " + value
+					}
+					result.Byline = &value
+				}
+			} else {
+				setText(t.Name, value)
+			}
+			stack = stack[:len(stack)-1]
+			text.Reset()
+		}
+	}
+}
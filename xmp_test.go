@@ -0,0 +1,73 @@
+package mfGalleryMetaCreatorGo
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testXMP(description string) string {
+	return `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/" ` + description + `
+</rdf:RDF></x:xmpmeta>`
+}
+
+// inserts the segment after the start of the jpeg
+func withSegment(image *fstest.MapFile, marker byte, payload []byte) *fstest.MapFile {
+	length := len(payload) + 2
+	data := append([]byte{0xFF, 0xD8, 0xFF, marker, byte(length >> 8), byte(length)}, payload...)
+	return &fstest.MapFile{Data: append(data, image.Data[2:]...)}
+}
+
+// inserts the xmp packet as APP1 segment
+func withEmbeddedXMP(image *fstest.MapFile, xmp string) *fstest.MapFile {
+	return withSegment(image, 0xE1, append([]byte(XMP_HEADER), xmp...))
+}
+
+func Test_parseXMP(t *testing.T) {
+	xmp, err := parseXMP([]byte(testXMP(`xmp:Rating="4" xmp:Label="Red">
+    <dc:title><rdf:Alt><rdf:li xml:lang="de">Gipfel</rdf:li><rdf:li xml:lang="x-default">Summit</rdf:li></rdf:Alt></dc:title>
+    <dc:subject><rdf:Bag><rdf:li>hike</rdf:li><rdf:li>alps</rdf:li></rdf:Bag></dc:subject>
+  </rdf:Description>`)))
+	require.NoError(t, err)
+	require.Equal(t, "Summit", *xmp.Title)
+	require.Nil(t, xmp.Description)
+	require.Equal(t, []string{"hike", "alps"}, xmp.Keywords)
+	require.Equal(t, 4, *xmp.Rating)
+	require.Equal(t, "Red", *xmp.Label)
+
+	// as elements
+	xmp, err = parseXMP([]byte(testXMP(`><xmp:Rating>-1</xmp:Rating></rdf:Description>`)))
+	require.NoError(t, err)
+	require.Equal(t, -1, *xmp.Rating)
+
+	_, err = parseXMP([]byte("<x:xmpmeta"))
+	require.Error(t, err)
+}
+
+func Test_Generate_xmp(t *testing.T) {
+	gallery := newTestGallery(t, fstest.MapFS{
+		"A/a.jpg": withEmbeddedXMP(testImage(t, 10, 10), testXMP(`xmp:Rating="2">
+      <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Embedded</rdf:li></rdf:Alt></dc:title>
+      <dc:description><rdf:Alt><rdf:li xml:lang="x-default">Embedded description</rdf:li></rdf:Alt></dc:description>
+    </rdf:Description>`)),
+		"A/a.xmp": &fstest.MapFile{Data: []byte(testXMP(`xmp:Rating="5"/>`))},
+		"A/b.jpg": testImage(t, 10, 10),
+	})
+	require.NoError(t, gallery.generate())
+
+	// the sidecar wins, the embedded xmp fills the rest
+	images := gallery.images("A")
+	require.Equal(t, 5, *images[0].Rating)
+	require.Equal(t, "Embedded", *images[0].Title)
+	require.Equal(t, "Embedded description", *images[0].Description)
+	require.Nil(t, images[1].Rating)
+
+	// a changed sidecar is read again
+	gallery.update("A/a.jpg.xmp", []byte(testXMP(`xmp:Label="Green"/>`)), false)
+	require.NoError(t, gallery.generate())
+	images = gallery.images("A")
+	require.Equal(t, "Green", *images[0].Label)
+	require.Equal(t, 2, *images[0].Rating)
+}